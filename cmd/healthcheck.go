@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runHealthcheck(args []string) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+
+	url := fs.String("url", "http://127.0.0.1:9123/healthz", "health endpoint of the running exporter")
+	timeout := fs.Duration("timeout", 5*time.Second, "request timeout")
+	metricsURL := fs.String("metrics-url", "http://127.0.0.1:9123/metrics", "metrics endpoint of the running exporter, used with -max-age")
+	metric := fs.String("metric", "validator_last_propose_timestamp", "unix timestamp gauge checked for freshness with -max-age")
+	maxAge := fs.Duration("max-age", 0, "fail when -metric is older than this (0 disables the freshness check)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(*url)
+	if err != nil {
+		return fmt.Errorf("healthcheck failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("healthcheck failed: %s returned %s", *url, resp.Status)
+	}
+	if *maxAge <= 0 {
+		return nil
+	}
+
+	ts, err := scrapeGauge(client, *metricsURL, *metric)
+	if err != nil {
+		return fmt.Errorf("healthcheck failed: %w", err)
+	}
+	if ts <= 0 {
+		return fmt.Errorf("healthcheck failed: %s has no value yet", *metric)
+	}
+	at := time.Unix(int64(ts), 0)
+	// an exporter run with -timestamp-precision millis reports milliseconds;
+	// in seconds such a value would lie thousands of years ahead
+	if ts > 1e11 {
+		at = time.UnixMilli(int64(ts))
+	}
+	if age := time.Since(at); age > *maxAge {
+		return fmt.Errorf("healthcheck failed: %s is %s old, max %s", *metric, age.Round(time.Second), *maxAge)
+	}
+	return nil
+}
+
+// scrapeGauge fetches a text exposition from url and returns the largest value
+// of metric across its series.
+func scrapeGauge(client *http.Client, url, metric string) (float64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	found := false
+	var value float64
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var rest string
+		switch {
+		case strings.HasPrefix(line, metric+" "):
+			rest = line[len(metric)+1:]
+		case strings.HasPrefix(line, metric+"{"):
+			end := strings.LastIndex(line, "} ")
+			if end == -1 {
+				continue
+			}
+			rest = line[end+2:]
+		default:
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", metric, err)
+		}
+		if !found || v > value {
+			value = v
+		}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("%s not found at %s", metric, url)
+	}
+	return value, nil
+}
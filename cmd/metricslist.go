@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"pharos-exporter/internal"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func runMetricsList(args []string) error {
+	fs := flag.NewFlagSet("metrics-list", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	logMetrics := internal.NewLogMetrics()
+	internal.RegisterMetrics(logMetrics)
+	internal.PrimeMetrics(logMetrics)
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tLABELS\tHELP")
+	for _, mf := range families {
+		seen := make(map[string]bool)
+		var labels []string
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if !seen[lp.GetName()] {
+					seen[lp.GetName()] = true
+					labels = append(labels, lp.GetName())
+				}
+			}
+		}
+		sort.Strings(labels)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mf.GetName(), strings.ToLower(mf.GetType().String()), strings.Join(labels, ","), mf.GetHelp())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"pharos-exporter/internal"
+)
+
+// runParseLog feeds a log file or stdin through the log matchers and prints
+// the resulting counters, without the RPC tracker or metrics server.
+func runParseLog(args []string) error {
+	fs := flag.NewFlagSet("parse-log", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	logPath := fs.String("log-path", "-", "log file to parse (- reads from stdin)")
+	myNodeId := fs.String("my-node-id", "", "my node id")
+	checkPropose := fs.Bool("check-propose", true, "check propose metrics")
+	checkEndorse := fs.Bool("check-endorse", true, "check endorse metrics")
+	logMaxLineBytes := fs.Int("log-max-line-bytes", 1<<20, "max bytes kept per log line, longer lines are truncated")
+	logMaxProposers := fs.Int("log-max-proposers", 100, "max distinct endorse proposers tracked before bucketing into \"other\"")
+	logProposerIdleTimeout := fs.Duration("log-proposer-idle-timeout", 0, "drop endorse proposers without endorses for this long, freeing their slot (0 keeps them)")
+	logTimeFormat := fs.String("log-time-format", "", "Go time layout of the leading log timestamp (default RFC3339)")
+	criticalPatterns := fs.String("critical-patterns", "", "comma-separated substrings of log lines counted in validator_critical_events_total, e.g. panic,disk full")
+	logTimeLocation := fs.String("log-time-location", "", "time zone of log timestamps without one, e.g. Asia/Seoul (default UTC)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *logPath != "-" {
+		f, err := os.Open(*logPath)
+		if err != nil {
+			return fmt.Errorf("open log: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	logMetrics := internal.NewLogMetrics()
+	tailer, err := internal.NewLogTailer(internal.LogTailerConfig{
+		MyNodeId:            *myNodeId,
+		Path:                *logPath,
+		Output:              os.Stderr,
+		Metrics:             logMetrics,
+		CheckPropose:        *checkPropose,
+		CheckEndorse:        *checkEndorse,
+		MaxProposers:        *logMaxProposers,
+		ProposerIdleTimeout: *logProposerIdleTimeout,
+		MaxLineBytes:        *logMaxLineBytes,
+		TimeFormat:          *logTimeFormat,
+		TimeLocation:        *logTimeLocation,
+		CriticalPatterns:    splitList(*criticalPatterns),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tailer.ReadAll(r); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(logMetrics.Snapshot())
+}
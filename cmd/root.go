@@ -13,6 +13,12 @@ func Execute() error {
 	switch os.Args[1] {
 	case "start":
 		return runStart(os.Args[2:])
+	case "healthcheck":
+		return runHealthcheck(os.Args[2:])
+	case "metrics-list":
+		return runMetricsList(os.Args[2:])
+	case "parse-log":
+		return runParseLog(os.Args[2:])
 	default:
 		return fmt.Errorf("unknown command: %s", os.Args[1])
 	}
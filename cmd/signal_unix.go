@@ -0,0 +1,11 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignal asks a running exporter to log its internal state.
+var dumpSignal os.Signal = syscall.SIGUSR1
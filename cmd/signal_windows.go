@@ -0,0 +1,8 @@
+//go:build windows
+
+package cmd
+
+import "os"
+
+// dumpSignal is unset on Windows, which has no SIGUSR1.
+var dumpSignal os.Signal
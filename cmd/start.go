@@ -2,110 +2,517 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"pharos-exporter/internal"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 )
 
+// defaultRPCURL is the -rpc default. Builds for other networks can replace it
+// with -ldflags "-X pharos-exporter/cmd.defaultRPCURL=https://...", or set it
+// empty to make -rpc mandatory.
+var defaultRPCURL = "https://atlantic-rpc.dplabs-internal.com/"
+
+// lowPollInterval is the RPC poll interval below which a warning is logged;
+// -min-poll-interval is the hard floor.
+const lowPollInterval = 250 * time.Millisecond
+
 func runStart(args []string) error {
 	fs := flag.NewFlagSet("start", flag.ContinueOnError)
 	fs.SetOutput(os.Stdout)
 
-	rpcURL := fs.String("rpc", "https://atlantic-rpc.dplabs-internal.com/", "JSON-RPC endpoint")
+	rpcURL := fs.String("rpc", defaultRPCURL, "JSON-RPC endpoint")
+	rpcTimeout := fs.Duration("rpc-timeout", 30*time.Second, "timeout for a single RPC request attempt")
+	rpcRateLimit := fs.Float64("rpc-rate-limit", 0, "max RPC requests per second, shared by all calls (0 disables)")
+	rpcProxy := fs.String("rpc-proxy", "", "proxy URL for RPC calls (http://, https:// or socks5://); overrides HTTP_PROXY")
+	rpcCACert := fs.String("rpc-ca-cert", "", "PEM CA bundle trusted for the RPC endpoint in addition to system roots")
+	rpcInsecureSkipVerify := fs.Bool("rpc-insecure-skip-verify", false, "skip RPC TLS certificate verification (INSECURE: allows man-in-the-middle, prefer -rpc-ca-cert)")
 	myBlsKey := fs.String("my-bls-key", "", "my BLS pubkey (0x...)")
+	myBlsKeyFile := fs.String("my-bls-key-file", "", "file containing -my-bls-key, keeping it out of process listings")
+	myBlsKeys := fs.String("my-bls-keys", "", "comma-separated BLS pubkeys of further validators to track alongside -my-bls-key")
+	myIdentityKey := fs.String("my-identity-key", "", "my validator identity key (0x...), used when -my-bls-key is not set")
+	myValidatorId := fs.String("my-validator-id", "", "my validator id, used when neither -my-bls-key nor -my-identity-key is set")
 	myAddress := fs.String("my-address", "", "my EVM address to track balance (0x...)")
+	myAddressFile := fs.String("my-address-file", "", "file containing -my-address, keeping it out of process listings")
+	minBalanceETH := fs.Float64("min-balance-eth", 0, "report validator_balance_below_threshold when the -my-address balance is below this many ETH (0 disables)")
+	tokenDecimals := fs.Int("token-decimals", 18, "decimals of the native token used to convert balances")
+	tokenContracts := fs.String("token-contracts", "", "comma-separated ERC-20 contract addresses to track balanceOf(my-address)")
 	myNodeId := fs.String("my-node-id", "", "my node id")
 	checkBlockProof := fs.Bool("check-block-proof", true, "check signedBlsKeys metrics")
+	ownBlocksOnly := fs.Bool("own-blocks-only", false, "only check block proofs of blocks proposed by -my-address (block miner)")
+	checkSyncing := fs.Bool("check-syncing", true, "check RPC node syncing status metrics")
 	checkValidatorSet := fs.Bool("check-validator-set", true, "check validator set metrics")
+	checkBalance := fs.Bool("check-balance", true, "check address and token balance metrics (requires -my-address)")
+	checkBlockTime := fs.Bool("check-block-time", true, "check latest block timestamp metrics")
+	checkPeers := fs.Bool("check-peers", false, "check RPC node peer count metrics")
 	checkPropose := fs.Bool("check-propose", true, "check propose metrics")
 	checkEndorse := fs.Bool("check-endorse", true, "check endorse metrics")
-	logPath := fs.String("log-path", "", "path to log file to tail")
+	criticalPatterns := fs.String("critical-patterns", "", "comma-separated substrings of log lines counted in validator_critical_events_total, e.g. panic,disk full")
+	methodBlockNumber := fs.String("method-block-number", "eth_blockNumber", "JSON-RPC method returning the latest block number, for forks that rename it")
+	methodBlockProof := fs.String("method-block-proof", "debug_getBlockProof", "JSON-RPC method returning the block proof, for forks that rename it")
+	methodValidatorInfo := fs.String("method-validator-info", "debug_getValidatorInfo", "JSON-RPC method returning the validator set, for forks that rename it")
+	probesFile := fs.String("probes-file", "", "JSON file defining extra RPC method probes exported as gauges")
+	logPath := fs.String("log-path", "", "path to log file to tail (- reads from stdin, ssh://[user@]host[:port]/path tails a remote file)")
 	logFromStart := fs.Bool("log-from-start", false, "start reading log from beginning (default: false)")
+	rpcFromHeight := fs.String("rpc-from-height", "", "height to start checking blocks from (number or earliest); default is the latest block")
+	rpcMaxBackfill := fs.Uint64("rpc-max-backfill", 10000, "max number of blocks behind the latest block that -rpc-from-height may start at")
+	confirmationDepth := fs.Uint64("confirmation-depth", 0, "only process heights at least this many blocks below the latest block")
+	skipFailedHeights := fs.Bool("skip-failed-heights", false, "skip heights whose block proof or validator set cannot be fetched (e.g. pruned by the RPC) instead of exiting")
 	rpcPollInterval := fs.Duration("rpc-poll-interval", time.Second, "poll interval for latest block")
+	balanceBlockTag := fs.String("balance-block-tag", "latest", "block of the address balance: latest, pending, earliest or a height")
+	minPollInterval := fs.Duration("min-poll-interval", 100*time.Millisecond, "reject -rpc-poll-interval or -balance-poll-interval below this, protecting shared RPCs from typos")
+	balancePollInterval := fs.Duration("balance-poll-interval", time.Minute, "poll interval for address and token balances")
+	catchupConcurrency := fs.Int("catchup-concurrency", 1, "number of heights fetched in parallel while catching up")
+	pollJitter := fs.Duration("poll-jitter", 0, "random +/- jitter applied to rpc poll interval (0 disables)")
+	logMaxBackfillBytes := fs.Int64("log-max-backfill-bytes", 0, "with -log-from-start, replay at most this many bytes before the end of an existing log (0 replays all)")
+	logTimeFormat := fs.String("log-time-format", "", "Go time layout of the leading log timestamp (default RFC3339)")
+	logTimeLocation := fs.String("log-time-location", "", "time zone of log timestamps without one, e.g. Asia/Seoul (default UTC)")
+	logCopyTo := fs.String("log-copy-to", "", "comma-separated files to append every tailed log line to (- for stdout)")
 	logPollInterval := fs.Duration("log-poll-interval", time.Second, "poll interval for log tailing")
-	exporterPort := fs.String("exporter-port", "9123", "metrics listen port")
+	logMaxLineBytes := fs.Int("log-max-line-bytes", 1<<20, "max bytes kept per log line, longer lines are truncated")
+	logMaxProposers := fs.Int("log-max-proposers", 100, "max distinct endorse proposers tracked before bucketing into \"other\"")
+	logProposerIdleTimeout := fs.Duration("log-proposer-idle-timeout", 0, "drop endorse proposers without endorses for this long, freeing their slot (0 keeps them)")
+	timestampPrecision := fs.String("timestamp-precision", "seconds", "unit of the vote, active and log event timestamp gauges: seconds or millis")
+	listenAddress := fs.String("listen-address", ":9123", "metrics listen address (host:port)")
+	exporterPort := fs.String("exporter-port", "", "deprecated: metrics listen port, use -listen-address")
+	scrapeTimeout := fs.Duration("scrape-timeout", 10*time.Second, "max time to serve a /metrics scrape before answering 503")
+	keepServing := fs.Bool("keep-serving-on-subsystem-failure", false, "log and restart a failed block tracker or log tailer instead of exiting, keeping /metrics up")
+	debugEndpoints := fs.Bool("debug-endpoints", false, "expose /debug/logmetrics JSON snapshot of log metrics")
+	internalListenAddress := fs.String("internal-listen-address", "", "optional internal listen address (host:port) serving /healthz, /debug/logmetrics and /debug/pprof; the main address then serves only /metrics")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	if *logPath == "" {
 		return errors.New("log-path is required")
 	}
+	if *rpcURL == "" {
+		return errors.New("rpc is required")
+	}
+	if *exporterPort != "" {
+		if flagWasSet(fs, "listen-address") {
+			return errors.New("exporter-port and listen-address are mutually exclusive")
+		}
+		log.Printf("-exporter-port is deprecated, use -listen-address :%s", *exporterPort)
+		*listenAddress = ":" + *exporterPort
+	}
+	if err := readValueFile(fs, "my-bls-key", myBlsKey, *myBlsKeyFile); err != nil {
+		return err
+	}
+	if err := readValueFile(fs, "my-address", myAddress, *myAddressFile); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"rpc-poll-interval", *rpcPollInterval},
+		{"balance-poll-interval", *balancePollInterval},
+	} {
+		if f.interval < *minPollInterval {
+			return fmt.Errorf("%s %s is below -min-poll-interval %s", f.name, f.interval, *minPollInterval)
+		}
+		if f.interval < lowPollInterval {
+			log.Printf("warning: %s %s is below %s and puts heavy load on the RPC", f.name, f.interval, lowPollInterval)
+		}
+	}
+	if *logMaxBackfillBytes < 0 {
+		return errors.New("log-max-backfill-bytes must not be negative")
+	}
+	if *rpcRateLimit < 0 {
+		return errors.New("rpc-rate-limit must not be negative")
+	}
+	if *scrapeTimeout <= 0 {
+		return errors.New("scrape-timeout must be positive")
+	}
+	if *timestampPrecision != "seconds" && *timestampPrecision != "millis" {
+		return fmt.Errorf("invalid timestamp-precision %q: must be seconds or millis", *timestampPrecision)
+	}
+	timestampMillis := *timestampPrecision == "millis"
+	listenHost, listenPort, err := parseListenAddress(*listenAddress)
+	if err != nil {
+		return err
+	}
+	if *internalListenAddress != "" {
+		if _, _, err := parseListenAddress(*internalListenAddress); err != nil {
+			return err
+		}
+	}
+	listener, err := listen("listen-address", *listenAddress)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	var internalListener net.Listener
+	if *internalListenAddress != "" {
+		if internalListener, err = listen("internal-listen-address", *internalListenAddress); err != nil {
+			return err
+		}
+		defer internalListener.Close()
+	}
+
+	if flagWasSet(fs, "rpc") {
+		log.Printf("Using RPC %s", internal.RPCEndpoint(*rpcURL))
+	} else {
+		log.Printf("Using default RPC %s, set -rpc for other networks", internal.RPCEndpoint(*rpcURL))
+	}
 
-	internal.RegisterMetrics()
+	logMetrics := internal.NewLogMetrics()
+	internal.RegisterMetrics(logMetrics)
+	internal.StartTime.Set(float64(time.Now().Unix()))
+	internal.RPCPollIntervalSeconds.Set(rpcPollInterval.Seconds())
+	internal.LogPollIntervalSeconds.Set(logPollInterval.Seconds())
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	g, gctx := errgroup.WithContext(ctx)
 
+	var probes []internal.ProbeConfig
+	if *probesFile != "" {
+		if probes, err = internal.LoadProbes(*probesFile); err != nil {
+			return err
+		}
+	}
+
 	tracker, err := internal.NewBlockTracker(internal.BlockTrackerConfig{
-		RPCURL:            *rpcURL,
-		MyBlsKey:          *myBlsKey,
-		MyAddress:         *myAddress,
-		CheckBlockProof:   *checkBlockProof,
-		CheckValidatorSet: *checkValidatorSet,
-		PollInterval:      *rpcPollInterval,
+		RPCURL:                *rpcURL,
+		RPCProxy:              *rpcProxy,
+		RPCCACert:             *rpcCACert,
+		RPCInsecureSkipVerify: *rpcInsecureSkipVerify,
+		RPCTimeout:            *rpcTimeout,
+		RPCRateLimit:          *rpcRateLimit,
+		MyBlsKey:              *myBlsKey,
+		MyBlsKeys:             splitList(*myBlsKeys),
+		MyIdentityKey:         *myIdentityKey,
+		MyValidatorID:         *myValidatorId,
+		MyAddress:             *myAddress,
+		CheckBlockProof:       *checkBlockProof,
+		OwnBlocksOnly:         *ownBlocksOnly,
+		CheckValidatorSet:     *checkValidatorSet,
+		CheckBlockTime:        *checkBlockTime,
+		CheckBalance:          *checkBalance,
+		CheckPeers:            *checkPeers,
+		CheckSyncing:          *checkSyncing,
+		FromHeight:            *rpcFromHeight,
+		MaxBackfill:           *rpcMaxBackfill,
+		TokenDecimals:         *tokenDecimals,
+		TokenContracts:        splitList(*tokenContracts),
+		PollInterval:          *rpcPollInterval,
+		PollJitter:            *pollJitter,
+		CatchupConcurrency:    *catchupConcurrency,
+		ConfirmationDepth:     *confirmationDepth,
+		SkipFailedHeights:     *skipFailedHeights,
+		MethodBlockNumber:     *methodBlockNumber,
+		MethodBlockProof:      *methodBlockProof,
+		MethodValidatorInfo:   *methodValidatorInfo,
+		TimestampMillis:       timestampMillis,
+		Probes:                probes,
+		BalancePollInterval:   *balancePollInterval,
+		BalanceBlockTag:       *balanceBlockTag,
+		MinBalanceETH:         *minBalanceETH,
 	})
 	if err != nil {
 		return err
 	}
+	for _, c := range tracker.ProbeCollectors() {
+		if err := prometheus.Register(c); err != nil {
+			return fmt.Errorf("register probe metric: %w", err)
+		}
+	}
 	g.Go(func() error {
-		return tracker.Start(gctx)
+		return supervise(gctx, "block_tracker", *keepServing, tracker.Start)
 	})
 
+	var sinks []io.Writer
+	for _, path := range splitList(*logCopyTo) {
+		if path == "-" {
+			sinks = append(sinks, os.Stdout)
+			continue
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open log copy: %w", err)
+		}
+		defer f.Close()
+		sinks = append(sinks, f)
+	}
+
 	tailer, err := internal.NewLogTailer(internal.LogTailerConfig{
-		MyNodeId:     *myNodeId,
-		Path:         *logPath,
-		PollInterval: *logPollInterval,
-		Output:       os.Stdout,
-		FromStart:    *logFromStart,
-		CheckPropose: *checkPropose,
-		CheckEndorse: *checkEndorse,
+		MyNodeId:            *myNodeId,
+		Path:                *logPath,
+		PollInterval:        *logPollInterval,
+		Output:              os.Stdout,
+		Metrics:             logMetrics,
+		FromStart:           *logFromStart,
+		CheckPropose:        *checkPropose,
+		CheckEndorse:        *checkEndorse,
+		MaxProposers:        *logMaxProposers,
+		ProposerIdleTimeout: *logProposerIdleTimeout,
+		MaxLineBytes:        *logMaxLineBytes,
+		MaxBackfillBytes:    *logMaxBackfillBytes,
+		TimeFormat:          *logTimeFormat,
+		TimeLocation:        *logTimeLocation,
+		CriticalPatterns:    splitList(*criticalPatterns),
+		Sinks:               sinks,
+		TimestampMillis:     timestampMillis,
 	})
 	if err != nil {
 		return err
 	}
 	g.Go(func() error {
-		return tailer.Start(gctx)
+		return supervise(gctx, "log_tailer", *keepServing, tailer.Start)
 	})
 
-	log.Printf("Metrics exposed at http://%s:%s/metrics", resolvePublicIP(), *exporterPort)
-	server := &http.Server{
-		Addr:    ":" + *exporterPort,
-		Handler: promhttp.Handler(),
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	dump := make(chan os.Signal, 1)
+	if dumpSignal != nil {
+		signal.Notify(dump, dumpSignal)
+		defer signal.Stop(dump)
 	}
 	g.Go(func() error {
-		err := server.ListenAndServe()
+		for {
+			select {
+			case <-gctx.Done():
+				return nil
+			case <-hup:
+				log.Printf("SIGHUP received, reopening %s", *logPath)
+				tailer.Reopen()
+			case <-dump:
+				dumpState(tracker, logMetrics)
+			}
+		}
+	})
+
+	if listenHost == "" || listenHost == "0.0.0.0" || listenHost == "::" {
+		listenHost = resolvePublicIP()
+	}
+	log.Printf("Metrics exposed at http://%s/metrics", net.JoinHostPort(listenHost, listenPort))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", noStore(http.TimeoutHandler(promhttp.Handler(), *scrapeTimeout, "metrics scrape timed out\n")))
+	if *internalListenAddress != "" {
+		log.Printf("Internal endpoints exposed at http://%s", *internalListenAddress)
+		internalMux := http.NewServeMux()
+		internalMux.HandleFunc("/healthz", healthzHandler)
+		internalMux.HandleFunc("/debug/logmetrics", logMetricsHandler(logMetrics))
+		internalMux.HandleFunc("/debug/pprof/", pprof.Index)
+		internalMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		internalMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		internalMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		internalMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		serveHTTP(g, gctx, internalListener, &http.Server{
+			Addr:    *internalListenAddress,
+			Handler: internalMux,
+		})
+	} else {
+		mux.HandleFunc("/healthz", healthzHandler)
+		if *debugEndpoints {
+			mux.HandleFunc("/debug/logmetrics", logMetricsHandler(logMetrics))
+		}
+	}
+	serveHTTP(g, gctx, listener, &http.Server{
+		Addr:    *listenAddress,
+		Handler: mux,
+	})
+
+	if err := internal.SdNotify("READY=1"); err != nil {
+		log.Printf("sd_notify READY failed: %v", err)
+	}
+	if watchdog := internal.SdWatchdogInterval(); watchdog > 0 {
+		g.Go(func() error {
+			return runWatchdog(gctx, tracker, watchdog)
+		})
+	}
+
+	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// dumpState logs the tracker progress and the log metrics snapshot.
+func dumpState(tracker *internal.BlockTracker, logMetrics *internal.LogMetrics) {
+	var b strings.Builder
+	tracker.DumpState(&b)
+	snapshot, err := json.Marshal(logMetrics.Snapshot())
+	if err != nil {
+		snapshot = []byte(err.Error())
+	}
+	log.Printf("state dump:\n%slog: %s", b.String(), snapshot)
+}
+
+// supervise runs a subsystem. With keep set, a failure is logged and the
+// subsystem restarted after a growing delay instead of ending the errgroup,
+// so the metrics server keeps serving the last known values.
+func supervise(ctx context.Context, name string, keep bool, run func(context.Context) error) error {
+	if !keep {
+		return run(ctx)
+	}
+	delay := time.Second
+	for {
+		err := run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		internal.SubsystemRestartsTotal.WithLabelValues(name).Inc()
+		log.Printf("%s failed, restarting in %s: %v", name, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, time.Minute)
+	}
+}
+
+// runWatchdog pings the systemd watchdog at half its timeout, but only while
+// the tracker keeps polling the RPC or catching up, so a wedged tracker gets
+// the process restarted.
+func runWatchdog(ctx context.Context, tracker *internal.BlockTracker, timeout time.Duration) error {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		last := tracker.LastPoll()
+		if last.IsZero() {
+			// still waiting for the first poll
+			last = started
+		}
+		if time.Since(last) > timeout {
+			continue
+		}
+		if err := internal.SdNotify("WATCHDOG=1"); err != nil {
+			log.Printf("sd_notify WATCHDOG failed: %v", err)
+		}
+	}
+}
+
+// listen binds addr for the -name flag before any subsystem starts, so a port
+// taken by another process fails the start at once with a clear message
+// rather than later inside the errgroup.
+func listen(name, addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		_, port, _ := net.SplitHostPort(addr)
+		return nil, fmt.Errorf("port %s already in use (-%s %s): stop the other process or choose another address", port, name, addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen on -%s %s: %w", name, addr, err)
+	}
+	return ln, nil
+}
+
+// serveHTTP runs server in g and shuts it down gracefully once ctx is done.
+func serveHTTP(g *errgroup.Group, ctx context.Context, ln net.Listener, server *http.Server) {
+	g.Go(func() error {
+		err := server.Serve(ln)
 		if errors.Is(err, http.ErrServerClosed) {
 			return nil
 		}
 		return err
 	})
 	g.Go(func() error {
-		<-gctx.Done()
+		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		return server.Shutdown(shutdownCtx)
 	})
+}
+
+// noStore marks responses as uncacheable so proxies between Prometheus and the
+// exporter never serve a stale scrape. Request headers such as Accept-Encoding
+// pass through untouched, so promhttp still negotiates gzip.
+func noStore(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		h.ServeHTTP(w, r)
+	})
+}
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return err
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+func logMetricsHandler(m *internal.LogMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// readValueFile sets *value to the whitespace-trimmed content of path, the
+// -<name>-file variant of flag name. Setting both is an error.
+func readValueFile(fs *flag.FlagSet, name string, value *string, path string) error {
+	if path == "" {
+		return nil
+	}
+	if flagWasSet(fs, name) {
+		return fmt.Errorf("%s and %s-file are mutually exclusive", name, name)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s-file: %w", name, err)
 	}
+	*value = strings.TrimSpace(string(b))
 	return nil
 }
 
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func parseListenAddress(addr string) (string, string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid listen-address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid listen-address %q: port must be 1-65535", addr)
+	}
+	return host, port, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func resolvePublicIP() string {
 	client := &http.Client{Timeout: 2 * time.Second}
 	resp, err := client.Get("https://ifconfig.me/ip")
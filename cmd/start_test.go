@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TestNoStoreKeepsGzip checks that the /metrics wrapping marks scrapes as
+// uncacheable without breaking promhttp's gzip negotiation.
+func TestNoStoreKeepsGzip(t *testing.T) {
+	srv := httptest.NewServer(noStore(http.TimeoutHandler(promhttp.Handler(), 5*time.Second, "metrics scrape timed out\n")))
+	defer srv.Close()
+
+	for _, gzipped := range []bool{true, false} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// set explicitly, so the transport leaves the response compressed
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		} else {
+			req.Header.Set("Accept-Encoding", "identity")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", got)
+		}
+		body := io.Reader(resp.Body)
+		if got := resp.Header.Get("Content-Encoding"); gzipped != (got == "gzip") {
+			t.Fatalf("gzip requested %v, got Content-Encoding %q", gzipped, got)
+		}
+		if gzipped {
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("gzip body: %v", err)
+			}
+			body = zr
+		}
+		text, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(text), "go_goroutines") {
+			t.Errorf("metrics body lacks go_goroutines (gzip %v):\n%.200s", gzipped, text)
+		}
+	}
+}
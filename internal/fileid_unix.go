@@ -0,0 +1,18 @@
+//go:build unix
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the inode of the file, used to detect log rotation.
+func fileID(_ string, info os.FileInfo) (uint64, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("failed to read inode info")
+	}
+	return uint64(stat.Ino), nil
+}
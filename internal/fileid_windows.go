@@ -0,0 +1,31 @@
+//go:build windows
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the NTFS file index of path, the Windows counterpart of an
+// inode, used to detect log rotation.
+func fileID(path string, _ os.FileInfo) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	h, err := syscall.CreateFile(p, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s for file info: %w", path, err)
+	}
+	defer syscall.CloseHandle(h)
+
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &d); err != nil {
+		return 0, fmt.Errorf("failed to read file info: %w", err)
+	}
+	return uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow), nil
+}
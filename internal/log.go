@@ -2,12 +2,16 @@ package internal
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 )
 
@@ -20,20 +24,87 @@ type LogTailerConfig struct {
 	Metrics      *LogMetrics
 	CheckPropose bool
 	CheckEndorse bool
+	MaxProposers int
+	// ProposerIdleTimeout drops a proposer from the endorse counts once it
+	// had no endorse for that long, so dead proposers do not pin a slot.
+	// Zero keeps proposers forever.
+	ProposerIdleTimeout time.Duration
+	MaxLineBytes        int
+	// MaxBackfillBytes bounds how much of an existing file FromStart replays:
+	// reading starts at the first line within that many bytes of EOF.
+	MaxBackfillBytes int64
+	// TimeFormat is the Go layout of the leading log timestamp, RFC3339 when
+	// empty. TimeLocation names the zone for layouts without one, UTC when
+	// empty.
+	TimeFormat   string
+	TimeLocation string
+	// CriticalPatterns are substrings of known-bad log lines (panics,
+	// consensus failures, ...) counted per pattern in any line.
+	CriticalPatterns []string
+	// Sinks receive a copy of every tailed line, e.g. to echo or archive
+	// the log stream. Lines are not copied when empty.
+	Sinks []io.Writer
+	// TimestampMillis exports the last propose and endorse timestamps in
+	// Unix milliseconds instead of seconds.
+	TimestampMillis bool
 }
 
+const (
+	defaultMaxLineBytes = 1 << 20
+	defaultMaxProposers = 100
+	maxPendingProposes  = 256
+	otherProposer       = "other"
+)
+
 type LogTailer struct {
-	cfg    LogTailerConfig
-	file   *os.File
-	reader *bufio.Reader
-	inode  uint64
-	offset int64
+	cfg      LogTailerConfig
+	src      logSource
+	sink     io.Writer
+	sinkErr  bool
+	reopenCh chan struct{}
 }
 
 type LogMetrics struct {
 	checkPropose bool
 	checkEndorse bool
 	nodeIdPrefix string
+	maxProposers int
+	proposerIdle time.Duration
+	timeFormat   string
+	timeLocation *time.Location
+	millis       bool
+	critical     []string
+	output       io.Writer
+	timeWarning  sync.Once
+
+	mu              sync.Mutex
+	proposeCount    uint64
+	lastProposeTs   int64
+	lastProposeSeq  uint64
+	hasProposeSeq   bool
+	endorseCount    uint64
+	endorseObserved uint64
+	lastEndorseTs   int64
+	endorseTotal    map[string]uint64
+	endorseSeen     map[string]time.Time
+	lastPrune       time.Time
+	evicted         uint64
+	proposeTimes    map[uint64]time.Time
+	criticalTotal   map[string]uint64
+	lastCriticalTs  int64
+}
+
+type LogMetricsSnapshot struct {
+	ProposeTotal          uint64            `json:"proposeTotal"`
+	LastProposeTimestamp  int64             `json:"lastProposeTimestamp"`
+	LastProposeSeq        *uint64           `json:"lastProposeSeq,omitempty"`
+	EndorseTotal          uint64            `json:"endorseTotal"`
+	EndorseObserved       uint64            `json:"endorseObserved"`
+	LastEndorseTimestamp  int64             `json:"lastEndorseTimestamp"`
+	EndorseByProposer     map[string]uint64 `json:"endorseByProposer"`
+	CriticalByPattern     map[string]uint64 `json:"criticalByPattern,omitempty"`
+	LastCriticalTimestamp int64             `json:"lastCriticalTimestamp,omitempty"`
+	ProposersEvicted      uint64            `json:"proposersEvicted"`
 }
 
 func NewLogTailer(cfg LogTailerConfig) (*LogTailer, error) {
@@ -46,23 +117,137 @@ func NewLogTailer(cfg LogTailerConfig) (*LogTailer, error) {
 	if cfg.Output == nil {
 		cfg.Output = os.Stdout
 	}
+	if cfg.MaxLineBytes <= 0 {
+		cfg.MaxLineBytes = defaultMaxLineBytes
+	}
 	if cfg.Metrics == nil {
 		cfg.Metrics = NewLogMetrics()
 	}
 	cfg.Metrics.checkPropose = cfg.CheckPropose
 	cfg.Metrics.checkEndorse = cfg.CheckEndorse
 	cfg.Metrics.nodeIdPrefix = nodeIdPrefix(cfg.MyNodeId)
-	return &LogTailer{cfg: cfg}, nil
+	cfg.Metrics.output = cfg.Output
+	cfg.Metrics.timeFormat = cfg.TimeFormat
+	cfg.Metrics.millis = cfg.TimestampMillis
+	cfg.Metrics.setCriticalPatterns(cfg.CriticalPatterns)
+	cfg.Metrics.timeLocation = time.UTC
+	if cfg.TimeLocation != "" {
+		loc, err := time.LoadLocation(cfg.TimeLocation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log time location: %w", err)
+		}
+		cfg.Metrics.timeLocation = loc
+	}
+	if cfg.MaxProposers > 0 {
+		cfg.Metrics.maxProposers = cfg.MaxProposers
+	}
+	cfg.Metrics.proposerIdle = cfg.ProposerIdleTimeout
+	t := &LogTailer{cfg: cfg, reopenCh: make(chan struct{}, 1)}
+	if len(cfg.Sinks) > 0 {
+		t.sink = io.MultiWriter(cfg.Sinks...)
+	}
+	switch {
+	case cfg.Path == stdinPath:
+		t.src = &stdinSource{t: t}
+	case strings.HasPrefix(cfg.Path, sshScheme):
+		target, err := parseSSHPath(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		t.src = &sshSource{t: t, target: target}
+	default:
+		t.src = &fileSource{t: t}
+	}
+	return t, nil
+}
+
+// Reopen asks the running tailer to close and reopen its log path, e.g. after
+// logrotate signals a rotation. It never blocks.
+func (t *LogTailer) Reopen() {
+	select {
+	case t.reopenCh <- struct{}{}:
+	default:
+	}
 }
 
 func NewLogMetrics() *LogMetrics {
-	return &LogMetrics{}
+	return &LogMetrics{
+		maxProposers: defaultMaxProposers,
+		endorseTotal: make(map[string]uint64),
+		endorseSeen:  make(map[string]time.Time),
+		proposeTimes: make(map[uint64]time.Time),
+	}
+}
+
+// stdinPath makes the tailer read lines from standard input instead of a file.
+const stdinPath = "-"
+
+// logSource is where a LogTailer reads its lines from: the log file, standard
+// input or a remote file over ssh. follow hands every line to the tailer until
+// ctx is cancelled or the source fails, and is called again when the tailer is
+// restarted.
+type logSource interface {
+	follow(ctx context.Context) error
 }
 
 func (t *LogTailer) Start(ctx context.Context) error {
-	startAtEnd := !t.cfg.FromStart
+	return t.src.follow(ctx)
+}
+
+// stdinSource reads the lines piped to the exporter.
+type stdinSource struct {
+	t  *LogTailer
+	in *os.File
+}
+
+func (s *stdinSource) follow(ctx context.Context) error {
+	if s.in == nil {
+		s.in = pollableStdin()
+	}
+	return s.t.stream(ctx, s.in)
+}
+
+// fileSource follows the log file at the configured path across rotations.
+type fileSource struct {
+	t       *LogTailer
+	file    *os.File
+	reader  *bufio.Reader
+	inode   uint64
+	offset  int64
+	started bool
+}
+
+func (s *fileSource) follow(ctx context.Context) error {
+	t := s.t
+	// a restarted tailer continues at the end instead of replaying the log
+	// and counting its events twice
+	backlog := int64(0)
+	if t.cfg.FromStart && !s.started {
+		backlog = wholeFile
+		if t.cfg.MaxBackfillBytes > 0 {
+			backlog = t.cfg.MaxBackfillBytes
+		}
+	}
+	s.started = true
 	for {
-		if err := t.openFile(startAtEnd); err != nil {
+		err := s.tail(ctx, backlog)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		// the path vanished mid-rotation: wait for it to come back and read
+		// the new file from the start instead of failing the exporter.
+		SubsystemRestartsTotal.WithLabelValues("log_tailer").Inc()
+		fmt.Fprintf(t.cfg.Output, "log %s disappeared, waiting for it to reappear: %v\n", t.cfg.Path, err)
+		backlog = wholeFile
+	}
+}
+
+// tail opens the log path, waiting for it to exist, and reads it until an
+// error occurs or ctx is cancelled.
+func (s *fileSource) tail(ctx context.Context, backlog int64) error {
+	t := s.t
+	for {
+		if err := s.openFile(backlog); err != nil {
 			if os.IsNotExist(err) {
 				if err := sleepWithContext(ctx, t.cfg.PollInterval); err != nil {
 					return err
@@ -73,21 +258,25 @@ func (t *LogTailer) Start(ctx context.Context) error {
 		}
 		break
 	}
-	defer t.closeFile()
+	defer s.closeFile()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-t.reopenCh:
+			if err := s.reopen(); err != nil {
+				return err
+			}
 		default:
 		}
 
-		line, err := t.reader.ReadBytes('\n')
+		line, n, err := t.readLine(s.reader)
 		if len(line) > 0 {
-			lineStr := string(line)
-			t.cfg.Metrics.Update(lineStr)
-			t.offset += int64(len(line))
+			t.handleLine(line)
 		}
+		s.offset += int64(n)
+		LogReadOffset.Set(float64(s.offset))
 		if err == nil {
 			continue
 		}
@@ -95,7 +284,7 @@ func (t *LogTailer) Start(ctx context.Context) error {
 			return err
 		}
 
-		rotated, rerr := t.reopenIfRotated()
+		rotated, rerr := s.reopenIfRotated()
 		if rerr != nil {
 			return rerr
 		}
@@ -108,27 +297,163 @@ func (t *LogTailer) Start(ctx context.Context) error {
 	}
 }
 
-func (t *LogTailer) reopenIfRotated() (bool, error) {
-	info, err := os.Stat(t.cfg.Path)
+// stream reads lines from r until EOF or until ctx is cancelled. Streams have no
+// file identity, so there is no rotation handling.
+func (t *LogTailer) stream(ctx context.Context, r io.Reader) error {
+	deadline, _ := r.(interface{ SetReadDeadline(time.Time) error })
+	if deadline != nil {
+		// clear the deadline left by a previous cancelled stream
+		_ = deadline.SetReadDeadline(time.Time{})
+	}
+	done := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, _, err := t.readLine(reader)
+			if len(line) > 0 && ctx.Err() == nil {
+				t.handleLine(line)
+			}
+			if err == io.EOF {
+				done <- nil
+				return
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	select {
+	case <-ctx.Done():
+		// interrupt the pending read and wait for the reader to return. An
+		// input without deadline support, such as a terminal, keeps its
+		// reader blocked until the next line or EOF, which is then dropped.
+		if deadline != nil && deadline.SetReadDeadline(time.Now()) == nil {
+			<-done
+		}
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// ReadAll counts the events of every line read from r until EOF, e.g. to
+// check log parsing against a captured sample without following a file.
+func (t *LogTailer) ReadAll(r io.Reader) error {
+	return t.stream(context.Background(), r)
+}
+
+// handleLine copies a tailed line to the sinks and counts its events. A sink
+// write error is reported once until a write succeeds again, so a full disk
+// does not flood the output.
+func (t *LogTailer) handleLine(line []byte) {
+	LogLinesReadTotal.Inc()
+	if t.sink != nil {
+		out := line
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			out = append(out, '\n')
+		}
+		if _, err := t.sink.Write(out); err != nil {
+			if !t.sinkErr {
+				fmt.Fprintf(t.cfg.Output, "warning: copy log line: %v\n", err)
+			}
+			t.sinkErr = true
+		} else {
+			t.sinkErr = false
+		}
+	}
+	t.cfg.Metrics.Update(string(line))
+}
+
+// readLine reads through the next newline but keeps at most MaxLineBytes of
+// it, so a huge line or a binary blob without newlines cannot exhaust memory.
+// It returns the kept bytes and the number of bytes consumed.
+func (t *LogTailer) readLine(r *bufio.Reader) ([]byte, int, error) {
+	var line []byte
+	n := 0
+	for {
+		chunk, err := r.ReadSlice('\n')
+		n += len(chunk)
+		if room := t.cfg.MaxLineBytes - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		// the newline is not content, a line of exactly MaxLineBytes fits
+		content := n
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+			content--
+		}
+		if content > t.cfg.MaxLineBytes {
+			LogOversizedLinesTotal.Inc()
+		}
+		LogBytesReadTotal.Add(float64(n))
+		return line, n, err
+	}
+}
+
+// reopen reopens the log path. It continues at the current offset when the
+// path still refers to the same file and reads the new file from the start
+// otherwise. If the path is missing, the current file is kept.
+func (s *fileSource) reopen() error {
+	info, err := os.Stat(s.t.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	inode, err := fileID(s.t.cfg.Path, info)
+	if err != nil {
+		return err
+	}
+	offset := s.offset
+	same := inode == s.inode && info.Size() >= offset
+
+	s.closeFile()
+	if err := s.openFile(wholeFile); err != nil {
+		return err
+	}
+	if same {
+		if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		s.reader.Reset(s.file)
+		s.offset = offset
+	}
+	return nil
+}
+
+func (s *fileSource) reopenIfRotated() (bool, error) {
+	info, err := os.Stat(s.t.cfg.Path)
 	if err != nil {
 		return false, err
 	}
-	inode, err := inodeFromInfo(info)
+	inode, err := fileID(s.t.cfg.Path, info)
 	if err != nil {
 		return false, err
 	}
-	if inode != t.inode || info.Size() < t.offset {
-		t.closeFile()
-		if err := t.openFile(false); err != nil {
+	LogFileSize.Set(float64(info.Size()))
+	if inode != s.inode || info.Size() < s.offset {
+		s.closeFile()
+		if err := s.openFile(wholeFile); err != nil {
 			return false, err
 		}
+		LogReadOffset.Set(float64(s.offset))
 		return true, nil
 	}
 	return false, nil
 }
 
-func (t *LogTailer) openFile(startAtEnd bool) error {
-	f, err := os.Open(t.cfg.Path)
+// wholeFile makes openFile read the file from its beginning.
+const wholeFile = -1
+
+// openFile opens the log path positioned backlog bytes before EOF, rounded
+// forward to the next line start. A backlog of 0 starts at EOF and wholeFile
+// at the beginning.
+func (s *fileSource) openFile(backlog int64) error {
+	f, err := os.Open(s.t.cfg.Path)
 	if err != nil {
 		return err
 	}
@@ -137,49 +462,87 @@ func (t *LogTailer) openFile(startAtEnd bool) error {
 		f.Close()
 		return err
 	}
-	inode, err := inodeFromInfo(info)
+	inode, err := fileID(s.t.cfg.Path, info)
 	if err != nil {
 		f.Close()
 		return err
 	}
 	offset := int64(0)
-	if startAtEnd {
-		if off, err := f.Seek(0, io.SeekEnd); err == nil {
-			offset = off
+	if backlog >= 0 && info.Size() > backlog {
+		if offset, err = f.Seek(info.Size()-backlog, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	s.file = f
+	s.reader = bufio.NewReader(f)
+	s.inode = inode
+	s.offset = offset
+	if backlog > 0 && offset > 0 {
+		// skip the partial line the seek landed in, unless it landed on a
+		// line boundary
+		prev := make([]byte, 1)
+		if _, err := f.ReadAt(prev, offset-1); err == nil && prev[0] != '\n' {
+			_, n, _ := s.t.readLine(s.reader)
+			s.offset += int64(n)
 		}
 	}
-	t.file = f
-	t.reader = bufio.NewReader(f)
-	t.inode = inode
-	t.offset = offset
 	return nil
 }
 
-func (t *LogTailer) closeFile() {
-	if t.file != nil {
-		_ = t.file.Close()
+func (s *fileSource) closeFile() {
+	if s.file != nil {
+		_ = s.file.Close()
 	}
-	t.file = nil
-	t.reader = nil
+	s.file = nil
+	s.reader = nil
 }
 
-func inodeFromInfo(info os.FileInfo) (uint64, error) {
-	stat, ok := info.Sys().(*syscall.Stat_t)
-	if !ok {
-		return 0, fmt.Errorf("failed to read inode info")
+// Update counts the events in a single log line. Matching works on bytes, so
+// invalid UTF-8 is harmless, and CRLF line endings are stripped first.
+func (m *LogMetrics) Update(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	logTime, hasTime := m.parseLogTime(line)
+	if !hasTime {
+		logTime = time.Now()
+	}
+	ts := logTime.Unix()
+	if m.millis {
+		ts = logTime.UnixMilli()
 	}
-	return stat.Ino, nil
-}
 
-func (m *LogMetrics) Update(line string) {
-	ts := parseLogTimestamp(line)
+	for _, pattern := range m.critical {
+		if strings.Contains(line, pattern) {
+			m.mu.Lock()
+			m.criticalTotal[pattern]++
+			m.lastCriticalTs = ts
+			m.mu.Unlock()
+		}
+	}
 
 	if strings.Contains(line, "Propose, seq:") {
 		if !m.checkPropose {
 			return
 		}
-		ProposeTotal.Inc()
-		LastProposeTimestamp.Set(float64(ts))
+		seq, hasSeq := parseProposeSeq(line)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// the node re-logs the same propose on retry, count each seq once
+		if hasSeq && m.hasProposeSeq && seq == m.lastProposeSeq {
+			return
+		}
+		if hasSeq {
+			m.lastProposeSeq = seq
+			m.hasProposeSeq = true
+			m.recordProposeTime(seq, logTime)
+		}
+		m.proposeCount++
+		m.lastProposeTs = ts
+		if hasTime {
+			LogTimestampSkew.Set(time.Since(logTime).Seconds())
+		} else {
+			m.warnNoTime(line)
+		}
 		return
 	}
 
@@ -187,30 +550,185 @@ func (m *LogMetrics) Update(line string) {
 		if !m.checkEndorse {
 			return
 		}
-		if m.nodeIdPrefix != "" {
-			if !endorseProposerMatches(line, m.nodeIdPrefix) {
-				return
+		proposer, ok := parseEndorseProposer(line)
+		if ok {
+			m.countEndorse(proposer)
+		}
+		m.mu.Lock()
+		m.endorseObserved++
+		m.mu.Unlock()
+		if m.nodeIdPrefix != "" && proposer != m.nodeIdPrefix {
+			return
+		}
+		m.mu.Lock()
+		m.endorseCount++
+		m.lastEndorseTs = ts
+		if hasTime {
+			LogTimestampSkew.Set(time.Since(logTime).Seconds())
+		} else {
+			m.warnNoTime(line)
+		}
+		if seq, ok := parseEndorseSeq(line); ok {
+			if proposed, ok := m.proposeTimes[seq]; ok {
+				// only the first endorse of a propose measures its latency
+				delete(m.proposeTimes, seq)
+				EndorseLatency.Observe(max(logTime.Sub(proposed).Seconds(), 0))
 			}
 		}
-		EndorseTotal.Inc()
-		LastEndorseTimestamp.Set(float64(ts))
+		m.mu.Unlock()
 		return
 	}
 }
 
-func parseLogTimestamp(line string) int64 {
-	if len(line) == 0 || line[0] != '[' {
-		return time.Now().Unix()
+// setCriticalPatterns sets the critical patterns, starting each at zero so
+// its series exists before the first match.
+func (m *LogMetrics) setCriticalPatterns(patterns []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.critical = nil
+	m.criticalTotal = make(map[string]uint64)
+	for _, p := range patterns {
+		if _, ok := m.criticalTotal[p]; p == "" || ok {
+			continue
+		}
+		m.critical = append(m.critical, p)
+		m.criticalTotal[p] = 0
+	}
+}
+
+// warnNoTime reports the first event line whose timestamp could not be parsed,
+// since its metrics then use the time the line was read.
+func (m *LogMetrics) warnNoTime(line string) {
+	m.timeWarning.Do(func() {
+		if m.output == nil {
+			return
+		}
+		layout := m.timeFormat
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		fmt.Fprintf(m.output, "warning: no %q timestamp in log line, using read time (see -log-time-format): %.200q\n", layout, line)
+	})
+}
+
+// recordProposeTime remembers when seq was proposed until its first endorse.
+// Proposes that are never endorsed are evicted oldest seq first beyond
+// maxPendingProposes. m.mu must be held.
+func (m *LogMetrics) recordProposeTime(seq uint64, t time.Time) {
+	if _, ok := m.proposeTimes[seq]; ok {
+		return
 	}
-	end := strings.IndexByte(line, ']')
-	if end <= 1 {
-		return time.Now().Unix()
+	if len(m.proposeTimes) >= maxPendingProposes {
+		oldest := seq
+		for s := range m.proposeTimes {
+			oldest = min(oldest, s)
+		}
+		delete(m.proposeTimes, oldest)
 	}
-	ts, err := time.Parse(time.RFC3339Nano, line[1:end])
-	if err != nil {
-		return time.Now().Unix()
+	m.proposeTimes[seq] = t
+}
+
+// countEndorse records an endorse for proposer. Once maxProposers distinct
+// proposers are tracked, any new proposer is bucketed into "other" so a large
+// validator set or garbage parses cannot grow the map without bound.
+func (m *LogMetrics) countEndorse(proposer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.pruneProposers(now)
+	if _, ok := m.endorseTotal[proposer]; !ok && len(m.endorseTotal) >= m.maxProposers {
+		proposer = otherProposer
 	}
-	return ts.Unix()
+	m.endorseTotal[proposer]++
+	m.endorseSeen[proposer] = now
+}
+
+// pruneProposers drops proposers without an endorse within proposerIdle. It
+// runs on endorses and snapshots but scans at most once per proposerIdle, so
+// an idle proposer is dropped between one and two timeouts after its last
+// endorse. Idleness uses the read time, not the log time, so replaying an old
+// log does not evict everything. m.mu must be held.
+func (m *LogMetrics) pruneProposers(now time.Time) {
+	if m.proposerIdle <= 0 || now.Sub(m.lastPrune) < m.proposerIdle {
+		return
+	}
+	m.lastPrune = now
+	for proposer, seen := range m.endorseSeen {
+		if now.Sub(seen) >= m.proposerIdle {
+			delete(m.endorseSeen, proposer)
+			delete(m.endorseTotal, proposer)
+			m.evicted++
+		}
+	}
+}
+
+// Snapshot returns a copy of the counters accumulated from the log so far.
+func (m *LogMetrics) Snapshot() LogMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneProposers(time.Now())
+	byProposer := make(map[string]uint64, len(m.endorseTotal))
+	for k, v := range m.endorseTotal {
+		byProposer[k] = v
+	}
+	var byPattern map[string]uint64
+	if len(m.criticalTotal) > 0 {
+		byPattern = make(map[string]uint64, len(m.criticalTotal))
+		for k, v := range m.criticalTotal {
+			byPattern[k] = v
+		}
+	}
+	var lastProposeSeq *uint64
+	if m.hasProposeSeq {
+		seq := m.lastProposeSeq
+		lastProposeSeq = &seq
+	}
+	return LogMetricsSnapshot{
+		ProposeTotal:          m.proposeCount,
+		LastProposeSeq:        lastProposeSeq,
+		LastProposeTimestamp:  m.lastProposeTs,
+		EndorseTotal:          m.endorseCount,
+		EndorseObserved:       m.endorseObserved,
+		LastEndorseTimestamp:  m.lastEndorseTs,
+		EndorseByProposer:     byProposer,
+		CriticalByPattern:     byPattern,
+		LastCriticalTimestamp: m.lastCriticalTs,
+		ProposersEvicted:      m.evicted,
+	}
+}
+
+// parseLogTime extracts the leading timestamp of a log line, either bracketed
+// ("[2006-01-02T15:04:05Z] ...") or at the start of the line. Without a
+// configured layout the first bare token is parsed as RFC3339. It reports
+// false for lines without a timestamp.
+func (m *LogMetrics) parseLogTime(line string) (time.Time, bool) {
+	var candidates []string
+	if strings.HasPrefix(line, "[") {
+		if end := strings.IndexByte(line, ']'); end > 1 {
+			candidates = append(candidates, line[1:end])
+		}
+	} else {
+		if end := strings.IndexAny(line, " \t"); end > 0 {
+			candidates = append(candidates, line[:end])
+		}
+		if m.timeFormat != "" && len(line) >= len(m.timeFormat) {
+			// layouts with spaces, assuming the value is as wide as the layout
+			candidates = append(candidates, line[:len(m.timeFormat)])
+		}
+	}
+	for _, token := range candidates {
+		var ts time.Time
+		var err error
+		if m.timeFormat == "" {
+			ts, err = time.Parse(time.RFC3339Nano, token)
+		} else {
+			ts, err = time.ParseInLocation(m.timeFormat, token, m.timeLocation)
+		}
+		if err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
 }
 
 func nodeIdPrefix(nodeID string) string {
@@ -222,15 +740,64 @@ func nodeIdPrefix(nodeID string) string {
 	return nodeID[:8]
 }
 
-func endorseProposerMatches(line, prefix string) bool {
+func parseProposeSeq(line string) (uint64, bool) {
+	idx := strings.Index(line, "Propose, seq:")
+	if idx == -1 {
+		return 0, false
+	}
+	rest := strings.TrimLeft(line[idx+len("Propose, seq:"):], " ")
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	seq, err := strconv.ParseUint(rest[:end], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+func parseEndorseSeq(line string) (uint64, bool) {
+	idx := strings.Index(line, "endorse seq ")
+	if idx == -1 {
+		return 0, false
+	}
+	rest := line[idx+len("endorse seq "):]
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	seq, err := strconv.ParseUint(rest[:end], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+func parseEndorseProposer(line string) (string, bool) {
 	idx := strings.Index(line, "proposer ")
 	if idx == -1 {
-		return false
+		return "", false
+	}
+	// node versions differ in quoting and 0x-prefixing the proposer id
+	rest := strings.TrimLeft(line[idx+len("proposer "):], " \"'")
+	rest = trim0x(rest)
+	if len(rest) < 8 {
+		return "", false
 	}
-	start := idx + len("proposer ")
-	if len(line) < start+8 {
-		return false
+	proposer := strings.ToLower(rest[:8])
+	if !isHex(proposer) {
+		return "", false
+	}
+	return proposer, true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
 	}
-	proposer := strings.ToLower(line[start : start+8])
-	return proposer == prefix
+	return true
 }
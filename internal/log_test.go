@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseLogTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name   string
+		format string
+		line   string
+		ok     bool
+	}{
+		{"bracketed", "", "[2024-05-01T12:30:45Z] INFO Propose, seq: 10", true},
+		{"bracketed with offset", "", "[2024-05-01T14:30:45+02:00] INFO Propose, seq: 10", true},
+		{"unbracketed", "", "2024-05-01T12:30:45Z INFO Propose, seq: 10", true},
+		{"unbracketed tab", "", "2024-05-01T12:30:45Z\tINFO Propose, seq: 10", true},
+		{"custom layout with space", "2006-01-02 15:04:05", "2024-05-01 12:30:45 INFO Propose, seq: 10", true},
+		{"custom layout bracketed", "2006/01/02 15:04:05", "[2024/05/01 12:30:45] INFO Propose, seq: 10", true},
+		{"no timestamp", "", "INFO Propose, seq: 10", false},
+		{"empty brackets", "", "[] INFO Propose, seq: 10", false},
+		{"timestamp not leading", "", "INFO 2024-05-01T12:30:45Z Propose, seq: 10", false},
+		{"empty line", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewLogMetrics()
+			m.timeFormat = tt.format
+			m.timeLocation = time.UTC
+			got, ok := m.parseLogTime(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseLogTime(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if ok && !got.Equal(want) {
+				t.Errorf("parseLogTime(%q) = %v, want %v", tt.line, got, want)
+			}
+		})
+	}
+}
+
+func TestParseEndorseProposer(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+		ok   bool
+	}{
+		{"bare", "endorse seq 12 proposer 1a2b3c4d5e6f", "1a2b3c4d", true},
+		{"0x prefixed", "endorse seq 12 proposer 0x1a2b3c4d5e6f", "1a2b3c4d", true},
+		{"upper case", "endorse seq 12 proposer 0X1A2B3C4D5E6F", "1a2b3c4d", true},
+		{"double quoted", `endorse seq 12 proposer "0x1a2b3c4d5e6f"`, "1a2b3c4d", true},
+		{"single quoted", "endorse seq 12 proposer '1a2b3c4d'", "1a2b3c4d", true},
+		{"followed by tokens", "endorse seq 12 proposer 1a2b3c4d, round 3", "1a2b3c4d", true},
+		{"extra spaces", "endorse seq 12 proposer   0x1a2b3c4d", "1a2b3c4d", true},
+		{"too short", "endorse seq 12 proposer 0x1a2b", "", false},
+		{"not hex", "endorse seq 12 proposer node-one", "", false},
+		{"missing", "endorse seq 12", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseEndorseProposer(tt.line)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseEndorseProposer(%q) = %q, %v, want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseProposeSeq(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want uint64
+		ok   bool
+	}{
+		{"plain", "Propose, seq: 42", 42, true},
+		{"trailing text", "Propose, seq: 42, round 3", 42, true},
+		{"extra spaces", "Propose, seq:    42  ", 42, true},
+		{"no space", "Propose, seq:42", 42, true},
+		{"max uint64", "Propose, seq: 18446744073709551615", 18446744073709551615, true},
+		{"overflow", "Propose, seq: 18446744073709551616", 0, false},
+		{"missing number", "Propose, seq: ", 0, false},
+		{"end of line", "Propose, seq:", 0, false},
+		{"non-numeric", "Propose, seq: abc", 0, false},
+		{"negative", "Propose, seq: -1", 0, false},
+		{"no marker", "Propose seq 42", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseProposeSeq(tt.line)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseProposeSeq(%q) = %d, %v, want %d, %v", tt.line, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReadLineMaxBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      string
+		oversized bool
+	}{
+		{"shorter", "1234567\n", "1234567\n", false},
+		{"exactly max", "12345678\n", "12345678", false},
+		{"exactly max at eof", "12345678", "12345678", false},
+		{"one over", "123456789\n", "12345678", true},
+		{"one over at eof", "123456789", "12345678", true},
+		{"beyond buffer", strings.Repeat("x", 100) + "\n", "xxxxxxxx", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tailer, err := NewLogTailer(LogTailerConfig{Path: stdinPath, MaxLineBytes: 8, Output: io.Discard})
+			if err != nil {
+				t.Fatalf("NewLogTailer: %v", err)
+			}
+			before := testutil.ToFloat64(LogOversizedLinesTotal)
+			line, n, _ := tailer.readLine(bufio.NewReaderSize(strings.NewReader(tt.input), 16))
+			if string(line) != tt.want || n != len(tt.input) {
+				t.Errorf("readLine = %q, %d, want %q, %d", line, n, tt.want, len(tt.input))
+			}
+			want := 0.0
+			if tt.oversized {
+				want = 1
+			}
+			if got := testutil.ToFloat64(LogOversizedLinesTotal) - before; got != want {
+				t.Errorf("counted %v oversized lines, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestStdinCancel follows a piped stdin, as with
+// `node | pharos-exporter start -log-path -`, and cancels while the reader is
+// blocked: Start must return only once the reader has stopped reading.
+func TestStdinCancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows pipes support no read deadlines")
+	}
+	r, w, err := inheritedPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = saved }()
+
+	tailer, err := NewLogTailer(LogTailerConfig{Path: stdinPath, CheckPropose: true, Output: io.Discard})
+	if err != nil {
+		t.Fatalf("NewLogTailer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- tailer.Start(ctx) }()
+
+	io.WriteString(w, "[2024-05-01T12:30:45Z] Propose, seq: 1\n")
+	for deadline := time.Now().Add(2 * time.Second); tailer.cfg.Metrics.Snapshot().ProposeTotal == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("propose line was not read")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Start error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+	// the tailer's pollable stdin shares the descriptor of r; close both
+	// together so neither finalizer closes a reused descriptor later
+	in := tailer.src.(*stdinSource).in
+	defer func() {
+		in.Close()
+		r.Close()
+	}()
+
+	// with the reader gone, the next line is still in the pipe
+	line := "[2024-05-01T12:30:46Z] Propose, seq: 2\n"
+	io.WriteString(w, line)
+	unread := make(chan string, 1)
+	go func() {
+		in.SetReadDeadline(time.Time{})
+		buf := make([]byte, len(line))
+		io.ReadFull(in, buf)
+		unread <- string(buf)
+	}()
+	select {
+	case got := <-unread:
+		if got != line {
+			t.Errorf("read %q after cancel, want the unread line %q", got, line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the line was consumed by a reader still running after Start returned")
+	}
+	if got := tailer.cfg.Metrics.Snapshot().ProposeTotal; got != 1 {
+		t.Errorf("ProposeTotal = %d, want 1", got)
+	}
+}
+
+// syncBuffer collects tailer output written from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestSSHReconnectDelay runs a fake ssh whose sessions end at once: the
+// reconnect delay grows while sessions fail and starts over after one that
+// counts as established.
+func TestSSHReconnectDelay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	tests := []struct {
+		name   string
+		stable time.Duration
+		want   []string
+	}{
+		{"failing sessions", time.Hour, []string{"10ms", "20ms", "40ms"}},
+		{"established sessions", 0, []string{"10ms", "10ms", "10ms"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := sshStableAfter
+			sshStableAfter = tt.stable
+			defer func() { sshStableAfter = saved }()
+
+			out := &syncBuffer{}
+			tailer, err := NewLogTailer(LogTailerConfig{Path: "ssh://node.example/var/log/node.log", PollInterval: 10 * time.Millisecond, Output: out})
+			if err != nil {
+				t.Fatalf("NewLogTailer: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() { errc <- tailer.Start(ctx) }()
+
+			var delays []string
+			for deadline := time.Now().Add(5 * time.Second); len(delays) < len(tt.want); {
+				if time.Now().After(deadline) {
+					t.Fatalf("got %d reconnects, want %d; output:\n%s", len(delays), len(tt.want), out)
+				}
+				time.Sleep(5 * time.Millisecond)
+				delays = delays[:0]
+				for _, line := range strings.Split(out.String(), "\n") {
+					if _, rest, ok := strings.Cut(line, "reconnecting in "); ok {
+						delay, _, _ := strings.Cut(rest, ":")
+						delays = append(delays, delay)
+					}
+				}
+			}
+			cancel()
+			<-errc
+			for i, want := range tt.want {
+				if delays[i] != want {
+					t.Errorf("reconnect delays = %v, want %v first", delays, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateLineEndingsAndBinary(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC).Unix()
+	tests := []struct {
+		name         string
+		line         string
+		proposes     uint64
+		proposeSeq   uint64
+		endorses     uint64
+		ownEndorses  uint64
+		wantProposer string
+	}{
+		{name: "crlf propose", line: "[2024-05-01T12:30:45Z] Propose, seq: 7\r\n", proposes: 1, proposeSeq: 7},
+		{name: "crlf endorse", line: "[2024-05-01T12:30:45Z] endorse seq 7 proposer abcdef01\r\n", endorses: 1, ownEndorses: 1, wantProposer: "abcdef01"},
+		{name: "bare cr", line: "[2024-05-01T12:30:45Z] Propose, seq: 8\r", proposes: 1, proposeSeq: 8},
+		{name: "binary around propose", line: "[2024-05-01T12:30:45Z] \xff\xfe\x00 Propose, seq: 9 \x80\x81\n", proposes: 1, proposeSeq: 9},
+		{name: "binary after proposer", line: "[2024-05-01T12:30:45Z] endorse seq 9 proposer 0x12345678\xff\xfe\r\n", endorses: 1, wantProposer: "12345678"},
+		{name: "binary only", line: "\xff\xfe\x00\x01garbage\x80\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tailer, err := NewLogTailer(LogTailerConfig{Path: stdinPath, MyNodeId: "0xabcdef0123", CheckPropose: true, CheckEndorse: true, Output: io.Discard})
+			if err != nil {
+				t.Fatalf("NewLogTailer: %v", err)
+			}
+			m := tailer.cfg.Metrics
+			m.Update(tt.line)
+			s := m.Snapshot()
+			if s.ProposeTotal != tt.proposes || s.EndorseObserved != tt.endorses || s.EndorseTotal != tt.ownEndorses {
+				t.Fatalf("proposes %d, endorses observed %d, own %d; want %d, %d, %d",
+					s.ProposeTotal, s.EndorseObserved, s.EndorseTotal, tt.proposes, tt.endorses, tt.ownEndorses)
+			}
+			if tt.proposes > 0 {
+				if s.LastProposeSeq == nil || *s.LastProposeSeq != tt.proposeSeq {
+					t.Errorf("LastProposeSeq = %v, want %d", s.LastProposeSeq, tt.proposeSeq)
+				}
+				if s.LastProposeTimestamp != ts {
+					t.Errorf("LastProposeTimestamp = %d, want the log time %d", s.LastProposeTimestamp, ts)
+				}
+			}
+			if tt.ownEndorses > 0 && s.LastEndorseTimestamp != ts {
+				t.Errorf("LastEndorseTimestamp = %d, want the log time %d", s.LastEndorseTimestamp, ts)
+			}
+			if tt.wantProposer != "" && s.EndorseByProposer[tt.wantProposer] != 1 {
+				t.Errorf("EndorseByProposer = %v, want %s counted once", s.EndorseByProposer, tt.wantProposer)
+			}
+		})
+	}
+}
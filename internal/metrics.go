@@ -9,57 +9,392 @@ import (
 var (
 	metricsOnce sync.Once
 
-	ProposeTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "validator_propose_total",
-		Help: "Total number of propose attempts observed in logs.",
+	StartTime = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pharos_exporter_start_time_seconds",
+		Help: "Unix timestamp when the exporter process started.",
 	})
-	LastProposeTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "validator_last_propose_timestamp",
-		Help: "Unix timestamp of the last propose event observed in logs.",
+	SubsystemRestartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pharos_exporter_restarts_total",
+		Help: "Total number of times a subsystem loop recovered from an error and restarted.",
+	}, []string{"subsystem"})
+	InflightRPCRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pharos_exporter_inflight_rpc_requests",
+		Help: "Number of RPC requests currently awaiting a response.",
 	})
-	EndorseTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "validator_endorse_total",
-		Help: "Total number of endorse events observed in logs.",
+	RPCPollIntervalSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pharos_exporter_rpc_poll_interval_seconds",
+		Help: "Configured poll interval for the latest block, in seconds.",
 	})
-	LastEndorseTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "validator_last_endorse_timestamp",
-		Help: "Unix timestamp of the last endorse event observed in logs.",
+	LogPollIntervalSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pharos_exporter_log_poll_interval_seconds",
+		Help: "Configured poll interval for log tailing, in seconds.",
 	})
 
-	VoteInclusionTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	VoteInclusionTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "validator_vote_inclusion_total",
 		Help: "Total number of blocks where the validator vote was included.",
+	}, []string{"key"})
+	OwnBlocksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_own_blocks_total",
+		Help: "Total number of processed blocks proposed by my address, counted with own blocks only.",
 	})
-	VoteInclusionTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+	OwnBlockVoteInclusionTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "validator_own_block_vote_inclusion_total",
+		Help: "Total number of blocks proposed by my address where the validator vote was included, counted with own blocks only.",
+	}, []string{"key"})
+	VoteInclusionTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "validator_vote_inclusion_timestamp",
 		Help: "Unix timestamp when the validator vote was last included.",
-	})
-	ActiveTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	}, []string{"key"})
+	VoteIncluded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_vote_included",
+		Help: "1 if the validator vote was included in the latest processed block, 0 otherwise.",
+	}, []string{"key"})
+	ActiveTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "validator_active_total",
 		Help: "Total number of blocks where the validator was active in the validator set.",
-	})
-	ActiveTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+	}, []string{"key"})
+	ActiveTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "validator_active_timestamp",
 		Help: "Unix timestamp when validator active status was last observed.",
+	}, []string{"key"})
+	BlocksProcessedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_blocks_processed_total",
+		Help: "Total number of block heights processed by the tracker.",
+	})
+	BlockProcessDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "validator_block_process_duration_seconds",
+		Help:    "Time spent fetching and evaluating a single block height.",
+		Buckets: prometheus.DefBuckets,
+	})
+	BlocksSkippedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_blocks_skipped_total",
+		Help: "Total number of heights skipped because their block proof or validator set could not be fetched.",
+	})
+	BlockFetchErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "validator_block_fetch_errors_total",
+		Help: "Total number of heights whose block proof or validator set could not be fetched, by method.",
+	}, []string{"method"})
+	CatchupActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_catchup_active",
+		Help: "1 while the tracker is replaying heights more than a few blocks behind the head, 0 when live.",
+	})
+	PollBusySecondsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_poll_busy_seconds_total",
+		Help: "Total time the block tracker spent fetching and processing between poll sleeps.",
+	})
+	PollIdleSecondsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_poll_idle_seconds_total",
+		Help: "Total time the block tracker spent sleeping between polls.",
+	})
+	ChainHeadHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "chain_head_height",
+		Help: "Latest block number reported by the RPC (via eth_blockNumber or -method-block-number).",
+	})
+	LastProcessedBlock = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_last_processed_block",
+		Help: "Height of the last block processed by the tracker.",
+	})
+	LastProcessedBlockHash = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_last_processed_block_hash",
+		Help: "Always 1, labeled with the block proof hash of the last processed block with a proof.",
+	}, []string{"hash"})
+	CatchupRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_catchup_remaining",
+		Help: "Number of blocks between the height being processed and the latest block.",
+	})
+	RPCLastSuccessTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_endpoint_last_success_timestamp",
+		Help: "Unix timestamp of the last successful eth_blockNumber call to the RPC endpoint.",
+	}, []string{"endpoint"})
+	RPCRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_request_errors_total",
+		Help: "Total number of failed RPC attempts by method and kind (transport, protocol, decode).",
+	}, []string{"endpoint", "method", "kind"})
+	RPCIDMismatchTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_id_mismatch_total",
+		Help: "Total number of RPC responses whose id did not match the request id.",
+	}, []string{"endpoint"})
+	BlockSignatureRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "network_block_signature_ratio",
+		Help: "Fraction of the validator set whose BLS key signed the latest processed block proof.",
+	})
+	SecondsSinceLastBlock = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "network_seconds_since_last_block",
+		Help: "Seconds elapsed since the timestamp of the latest block reported by the RPC.",
+	})
+	LogOversizedLinesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_log_oversized_lines_total",
+		Help: "Total number of log lines truncated because they exceeded the max line length.",
+	})
+	LogLinesReadTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_log_lines_read_total",
+		Help: "Total number of log lines read by the tailer.",
+	})
+	LogBytesReadTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "validator_log_bytes_read_total",
+		Help: "Total number of log bytes read by the tailer.",
+	})
+	EndorseLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "validator_endorse_latency_seconds",
+		Help:    "Time from a propose to the first endorse of the same seq, from log timestamps.",
+		Buckets: prometheus.DefBuckets,
+	})
+	LogTimestampSkew = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_log_timestamp_skew_seconds",
+		Help: "Seconds between now and the log timestamp of the last matched propose or endorse event.",
+	})
+	LogReadOffset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_log_read_offset",
+		Help: "Byte offset the log tailer has read up to in the current log file.",
+	})
+	LogFileSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "validator_log_file_size",
+		Help: "Size in bytes of the tailed log file at the last rotation check.",
+	})
+	IsActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_is_active",
+		Help: "1 if the validator is in the validator set of the latest processed block, 0 otherwise.",
+	}, []string{"key"})
+	ActiveBlocksWindow = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_active_blocks_last_100",
+		Help: "Number of the last 100 processed blocks where the validator was in the validator set.",
+	}, []string{"key"})
+	ValidatorIDInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_info",
+		Help: "Always 1, labeled with the on-chain validator id learned from the validator set.",
+	}, []string{"key", "validator_id"})
+	KeyInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_key_info",
+		Help: "Always 1, labeled with a tracked BLS key and the configured address (empty when unset).",
+	}, []string{"bls_key", "address"})
+	ValidatorStaking = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_staking_eth",
+		Help: "Staking amount of the validator from the validator set, in ETH.",
+	}, []string{"key"})
+	PeerCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "network_peer_count",
+		Help: "Number of peers connected to the RPC node (via net_peerCount).",
+	})
+	NodeIsSyncing = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "node_is_syncing",
+		Help: "1 if the RPC node reports it is syncing (via eth_syncing), 0 otherwise.",
+	})
+	NodeSyncHighestBlock = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "node_sync_highest_block",
+		Help: "Highest block known to the RPC node while it is syncing (via eth_syncing).",
 	})
 	AddressBalanceETH = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "validator_address_balance_eth",
-		Help: "ETH balance of the configured address (via eth_getBalance)",
+		Help: "ETH balance of the configured address (via eth_getBalance). A float64 approximation of the wei balance, exact to about 15 significant digits.",
 	}, []string{"address"})
+	BalanceBelowThreshold = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_balance_below_threshold",
+		Help: "1 if the ETH balance of the configured address is below -min-balance-eth, 0 otherwise.",
+	}, []string{"address"})
+	TokenBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "validator_token_balance",
+		Help: "ERC-20 token balance of the configured address (via eth_call balanceOf). A float64 approximation, exact to about 15 significant digits.",
+	}, []string{"token", "address"})
+)
+
+// Log-derived metrics are reported by logMetricsCollector at scrape time
+// from LogMetrics.Snapshot rather than pushed into package-level metrics.
+var (
+	proposeTotalDesc = prometheus.NewDesc(
+		"validator_propose_total",
+		"Total number of propose attempts observed in logs.",
+		nil, nil,
+	)
+	lastProposeTimestampDesc = prometheus.NewDesc(
+		"validator_last_propose_timestamp",
+		"Unix timestamp of the last propose event observed in logs.",
+		nil, nil,
+	)
+	lastProposeSeqDesc = prometheus.NewDesc(
+		"validator_last_propose_seq",
+		"Sequence number of the last propose event observed in logs.",
+		nil, nil,
+	)
+	endorseTotalDesc = prometheus.NewDesc(
+		"validator_endorse_total",
+		"Total number of endorse events observed in logs for proposals by -my-node-id, or all endorse events when it is unset.",
+		nil, nil,
+	)
+	endorseObservedTotalDesc = prometheus.NewDesc(
+		"validator_endorse_observed_total",
+		"Total number of endorse events observed in logs, regardless of proposer.",
+		nil, nil,
+	)
+	lastEndorseTimestampDesc = prometheus.NewDesc(
+		"validator_last_endorse_timestamp",
+		"Unix timestamp of the last endorse event observed in logs.",
+		nil, nil,
+	)
+	endorseProposerTotalDesc = prometheus.NewDesc(
+		"validator_endorse_proposer_total",
+		"Total number of endorse events observed in logs by proposer node id prefix.",
+		[]string{"proposer"}, nil,
+	)
+	trackedProposersDesc = prometheus.NewDesc(
+		"validator_tracked_proposers",
+		"Number of distinct proposers tracked by validator_endorse_proposer_total.",
+		nil, nil,
+	)
+	proposersEvictedTotalDesc = prometheus.NewDesc(
+		"validator_endorse_proposers_evicted_total",
+		"Total number of proposers dropped from validator_endorse_proposer_total after -log-proposer-idle-timeout without endorses.",
+		nil, nil,
+	)
+	criticalEventsTotalDesc = prometheus.NewDesc(
+		"validator_critical_events_total",
+		"Total number of log lines matching each configured critical pattern.",
+		[]string{"pattern"}, nil,
+	)
+	lastCriticalTimestampDesc = prometheus.NewDesc(
+		"validator_last_critical_timestamp",
+		"Unix timestamp of the last log line matching a critical pattern.",
+		nil, nil,
+	)
 )
 
-func RegisterMetrics() {
+type logMetricsCollector struct {
+	m *LogMetrics
+}
+
+func NewLogMetricsCollector(m *LogMetrics) prometheus.Collector {
+	return &logMetricsCollector{m: m}
+}
+
+func (c *logMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- proposeTotalDesc
+	ch <- lastProposeTimestampDesc
+	ch <- lastProposeSeqDesc
+	ch <- endorseTotalDesc
+	ch <- endorseObservedTotalDesc
+	ch <- lastEndorseTimestampDesc
+	ch <- endorseProposerTotalDesc
+	ch <- trackedProposersDesc
+	ch <- proposersEvictedTotalDesc
+	ch <- criticalEventsTotalDesc
+	ch <- lastCriticalTimestampDesc
+}
+
+func (c *logMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	snap := c.m.Snapshot()
+	ch <- prometheus.MustNewConstMetric(proposeTotalDesc, prometheus.CounterValue, float64(snap.ProposeTotal))
+	ch <- prometheus.MustNewConstMetric(lastProposeTimestampDesc, prometheus.GaugeValue, float64(snap.LastProposeTimestamp))
+	if snap.LastProposeSeq != nil {
+		ch <- prometheus.MustNewConstMetric(lastProposeSeqDesc, prometheus.GaugeValue, float64(*snap.LastProposeSeq))
+	}
+	ch <- prometheus.MustNewConstMetric(endorseTotalDesc, prometheus.CounterValue, float64(snap.EndorseTotal))
+	ch <- prometheus.MustNewConstMetric(endorseObservedTotalDesc, prometheus.CounterValue, float64(snap.EndorseObserved))
+	ch <- prometheus.MustNewConstMetric(lastEndorseTimestampDesc, prometheus.GaugeValue, float64(snap.LastEndorseTimestamp))
+	for proposer, n := range snap.EndorseByProposer {
+		ch <- prometheus.MustNewConstMetric(endorseProposerTotalDesc, prometheus.CounterValue, float64(n), proposer)
+	}
+	ch <- prometheus.MustNewConstMetric(trackedProposersDesc, prometheus.GaugeValue, float64(len(snap.EndorseByProposer)))
+	ch <- prometheus.MustNewConstMetric(proposersEvictedTotalDesc, prometheus.CounterValue, float64(snap.ProposersEvicted))
+	if snap.CriticalByPattern != nil {
+		for pattern, n := range snap.CriticalByPattern {
+			ch <- prometheus.MustNewConstMetric(criticalEventsTotalDesc, prometheus.CounterValue, float64(n), pattern)
+		}
+		ch <- prometheus.MustNewConstMetric(lastCriticalTimestampDesc, prometheus.GaugeValue, float64(snap.LastCriticalTimestamp))
+	}
+}
+
+// PrimeMetrics creates a child with empty label values for every labeled
+// metric and marks optional log metrics as seen, so that a Gather lists the
+// full metric surface. It is meant for metrics-list only, a running exporter
+// would export the placeholders as real series.
+func PrimeMetrics(m *LogMetrics) {
+	SubsystemRestartsTotal.WithLabelValues("")
+	RPCLastSuccessTimestamp.WithLabelValues("")
+	RPCRequestErrorsTotal.WithLabelValues("", "", "")
+	RPCIDMismatchTotal.WithLabelValues("")
+	BlockFetchErrorsTotal.WithLabelValues("")
+	VoteInclusionTotal.WithLabelValues("")
+	VoteInclusionTimestamp.WithLabelValues("")
+	OwnBlockVoteInclusionTotal.WithLabelValues("")
+	VoteIncluded.WithLabelValues("")
+	ActiveTotal.WithLabelValues("")
+	ActiveTimestamp.WithLabelValues("")
+	IsActive.WithLabelValues("")
+	ActiveBlocksWindow.WithLabelValues("")
+	ValidatorIDInfo.WithLabelValues("", "")
+	LastProcessedBlockHash.WithLabelValues("")
+	KeyInfo.WithLabelValues("", "")
+	ValidatorStaking.WithLabelValues("")
+	AddressBalanceETH.WithLabelValues("")
+	BalanceBelowThreshold.WithLabelValues("")
+	TokenBalance.WithLabelValues("", "")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hasProposeSeq = true
+	m.endorseTotal[""] = 0
+	m.criticalTotal = map[string]uint64{"": 0}
+}
+
+// RegisterMetrics registers the exporter's metrics with the default registry.
+// Repeated calls are no-ops.
+func RegisterMetrics(logMetrics *LogMetrics) {
 	metricsOnce.Do(func() {
-		prometheus.MustRegister(
-			ProposeTotal,
-			LastProposeTimestamp,
-			EndorseTotal,
-			LastEndorseTimestamp,
-			VoteInclusionTotal,
-			VoteInclusionTimestamp,
-			ActiveTotal,
-			ActiveTimestamp,
-			AddressBalanceETH,
-		)
+		MustRegisterMetrics(prometheus.DefaultRegisterer, logMetrics)
 	})
 }
+
+// MustRegisterMetrics registers the exporter's metrics with reg, e.g. a custom
+// registry of an application embedding the exporter. The metrics are package
+// level, so they are shared by every registry they are registered with. It
+// panics if a metric is already registered with reg.
+func MustRegisterMetrics(reg prometheus.Registerer, logMetrics *LogMetrics) {
+	reg.MustRegister(
+		NewLogMetricsCollector(logMetrics),
+		StartTime,
+		SubsystemRestartsTotal,
+		InflightRPCRequests,
+		RPCPollIntervalSeconds,
+		LogPollIntervalSeconds,
+		VoteInclusionTotal,
+		OwnBlocksTotal,
+		OwnBlockVoteInclusionTotal,
+		VoteInclusionTimestamp,
+		VoteIncluded,
+		ActiveTotal,
+		ActiveTimestamp,
+		IsActive,
+		ActiveBlocksWindow,
+		ValidatorIDInfo,
+		KeyInfo,
+		ValidatorStaking,
+		BlocksProcessedTotal,
+		BlockProcessDuration,
+		BlocksSkippedTotal,
+		BlockFetchErrorsTotal,
+		CatchupActive,
+		ChainHeadHeight,
+		LastProcessedBlock,
+		LastProcessedBlockHash,
+		CatchupRemaining,
+		PollBusySecondsTotal,
+		PollIdleSecondsTotal,
+		RPCLastSuccessTimestamp,
+		RPCRequestErrorsTotal,
+		RPCIDMismatchTotal,
+		SecondsSinceLastBlock,
+		BlockSignatureRatio,
+		PeerCount,
+		NodeIsSyncing,
+		NodeSyncHighestBlock,
+		LogOversizedLinesTotal,
+		LogLinesReadTotal,
+		LogBytesReadTotal,
+		EndorseLatency,
+		LogTimestampSkew,
+		LogReadOffset,
+		LogFileSize,
+		AddressBalanceETH,
+		BalanceBelowThreshold,
+		TokenBalance,
+	)
+}
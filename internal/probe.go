@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProbeConfig describes a generic JSON-RPC probe: Method is called with Params
+// on every poll tick and the number found at Path in the result is exported as
+// a gauge named Metric.
+//
+// Path is a dot-separated list of object keys and array indexes into the
+// result, e.g. "pending.count" or "peers.0.latency". An optional leading "$."
+// is accepted; an empty path uses the result itself. Numbers, decimal or
+// 0x-hex strings and booleans (1/0) are accepted as values.
+type ProbeConfig struct {
+	Metric string        `json:"metric"`
+	Help   string        `json:"help"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Path   string        `json:"path"`
+}
+
+type probe struct {
+	cfg      ProbeConfig
+	gauge    prometheus.Gauge
+	disabled bool
+}
+
+// LoadProbes reads a JSON array of probe definitions from path.
+func LoadProbes(path string) ([]ProbeConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read probes file: %w", err)
+	}
+	var probes []ProbeConfig
+	if err := json.Unmarshal(b, &probes); err != nil {
+		return nil, fmt.Errorf("parse probes file %s: %w", path, err)
+	}
+	for i, p := range probes {
+		if p.Metric == "" || p.Method == "" {
+			return nil, fmt.Errorf("probe %d in %s: metric and method are required", i, path)
+		}
+	}
+	return probes, nil
+}
+
+func newProbes(cfgs []ProbeConfig) []*probe {
+	probes := make([]*probe, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		help := cfg.Help
+		if help == "" {
+			help = fmt.Sprintf("Value at %q of the %s RPC result.", cfg.Path, cfg.Method)
+		}
+		if cfg.Params == nil {
+			cfg.Params = []interface{}{}
+		}
+		probes = append(probes, &probe{
+			cfg: cfg,
+			gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: cfg.Metric,
+				Help: help,
+			}),
+		})
+	}
+	return probes
+}
+
+// ProbeCollectors returns the gauges of the configured RPC probes so the caller
+// can register them.
+func (m *BlockTracker) ProbeCollectors() []prometheus.Collector {
+	out := make([]prometheus.Collector, 0, len(m.probes))
+	for _, p := range m.probes {
+		out = append(out, p.gauge)
+	}
+	return out
+}
+
+// runProbes evaluates every probe once. Each probe gets a bounded number of
+// attempts, so failures are reported but never stop the tracker; a probe whose
+// method is not supported is disabled.
+func (m *BlockTracker) runProbes(ctx context.Context) error {
+	for _, p := range m.probes {
+		if p.disabled {
+			continue
+		}
+		resultRaw, err := rpcPostAttempts(ctx, m.rpc, p.cfg.Method, p.cfg.Params, optionalCheckAttempts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isMethodNotFound(err) {
+			fmt.Fprintf(m.cfg.Output, "warning: probe %s: %s is not supported by the RPC, disabling probe\n", p.cfg.Metric, p.cfg.Method)
+			p.disabled = true
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(m.cfg.Output, "warning: probe %s failed: %v\n", p.cfg.Metric, err)
+			continue
+		}
+		v, err := extractNumber(resultRaw, p.cfg.Path)
+		if err != nil {
+			fmt.Fprintf(m.cfg.Output, "warning: probe %s: %v\n", p.cfg.Metric, err)
+			continue
+		}
+		p.gauge.Set(v)
+	}
+	return nil
+}
+
+func extractNumber(raw json.RawMessage, path string) (float64, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return 0, fmt.Errorf("decode result: %w", err)
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch node := v.(type) {
+			case map[string]interface{}:
+				next, ok := node[key]
+				if !ok {
+					return 0, fmt.Errorf("path %q: key %q not found", path, key)
+				}
+				v = next
+			case []interface{}:
+				idx, err := strconv.Atoi(key)
+				if err != nil || idx < 0 || idx >= len(node) {
+					return 0, fmt.Errorf("path %q: invalid index %q", path, key)
+				}
+				v = node[idx]
+			default:
+				return 0, fmt.Errorf("path %q: cannot descend into %q", path, key)
+			}
+		}
+	}
+
+	switch x := v.(type) {
+	case json.Number:
+		return x.Float64()
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if strings.HasPrefix(x, "0x") || strings.HasPrefix(x, "0X") {
+			n, err := parseHexBigInt(x)
+			if err != nil {
+				return 0, fmt.Errorf("path %q: %w", path, err)
+			}
+			f, _ := new(big.Float).SetInt(n).Float64()
+			return f, nil
+		}
+		return strconv.ParseFloat(x, 64)
+	default:
+		return 0, fmt.Errorf("path %q: value is not numeric", path)
+	}
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces calls evenly at a fixed rate without bursts. Waiters
+// reserve consecutive slots, so concurrent catch-up fetches share the budget.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimiter returns a limiter allowing perSecond calls per second, or nil
+// (no limit) when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the caller's slot comes up or ctx is done. A nil limiter
+// never blocks.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepWithContext(ctx, time.Until(slot))
+}
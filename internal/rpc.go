@@ -2,37 +2,143 @@ package internal
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type BlockTrackerConfig struct {
-	RPCURL            string
-	MyBlsKey          string
-	MyAddress         string
-	CheckBlockProof   bool
-	CheckValidatorSet bool
-	PollInterval      time.Duration
-	Output            io.Writer
+	RPCURL                string
+	RPCProxy              string
+	RPCCACert             string
+	RPCInsecureSkipVerify bool
+	RPCTimeout            time.Duration
+	RPCRateLimit          float64
+	MyBlsKey              string
+	MyBlsKeys             []string
+	MyIdentityKey         string
+	MyValidatorID         string
+	MyAddress             string
+	CheckBlockProof       bool
+	OwnBlocksOnly         bool
+	CheckValidatorSet     bool
+	CheckBlockTime        bool
+	CheckBalance          bool
+	CheckPeers            bool
+	CheckSyncing          bool
+	FromHeight            string
+	MaxBackfill           uint64
+	TokenDecimals         int
+	TokenContracts        []string
+	PollInterval          time.Duration
+	PollJitter            time.Duration
+	CatchupConcurrency    int
+	ConfirmationDepth     uint64
+	SkipFailedHeights     bool
+	MethodBlockNumber     string
+	MethodBlockProof      string
+	MethodValidatorInfo   string
+	TimestampMillis       bool
+	Probes                []ProbeConfig
+	BalancePollInterval   time.Duration
+	BalanceBlockTag       string
+	MinBalanceETH         float64
+	Output                io.Writer
 }
 
 type BlockTracker struct {
-	cfg           BlockTrackerConfig
-	normalizedKey string
-	address       string
+	cfg             BlockTrackerConfig
+	rpc             *rpcClient
+	tracked         []*trackedValidator
+	lastPoll        atomic.Int64
+	lastChecked     atomic.Uint64
+	byBlsKey        map[string]*trackedValidator
+	address         string
+	tokens          []string
+	tokenDecimals   map[string]int
+	tokenFailures   map[string]int
+	headHeight      uint64
+	headTimestamp   int64
+	fromHeight      uint64
+	probes          []*probe
+	peerFailures    int
+	syncFailures    int
+	balanceFailures int
+	balanceOff      bool
+	started         bool
 }
 
+// rpcClient is the shared HTTP client used for every JSON-RPC call made by a
+// BlockTracker. Its RPC metrics are labeled with endpoint, the scheme and host
+// of url only, so credentials or API keys in the userinfo, path or query never
+// end up in labels.
+type rpcClient struct {
+	url        string
+	endpoint   string
+	httpClient *http.Client
+	limiter    *rateLimiter
+	// lastID is the id of the most recent request; each request gets a fresh
+	// one so a response meant for another request can be detected.
+	lastID atomic.Uint64
+}
+
+// defaultMaxBackfill bounds how far behind the head an explicit FromHeight may
+// start, so a typo cannot make the tracker replay the whole chain.
+const defaultMaxBackfill = 10000
+
+// catchupLogEvery is how many heights are processed between progress lines
+// while the tracker is behind the chain head.
+const catchupLogEvery = 1000
+
+// rpcPreflightAttempts is how many times Start tries to reach the RPC before
+// giving up, so an RPC that comes up shortly after the exporter does not fail
+// the boot while a wrong URL still fails with a clear error.
+const rpcPreflightAttempts = 5
+
+// heightFetchAttempts bounds the retries of a height's proof and validator
+// fetches when SkipFailedHeights is set, e.g. for heights an RPC has pruned.
+const heightFetchAttempts = 3
+
+// optionalCheckAttempts bounds the retries of optional per-poll calls such as
+// probes, so an RPC that refuses one of them cannot stall block processing.
+const optionalCheckAttempts = 3
+
+// optionalCheckMaxFailures is how many polls in a row an optional check may
+// fail before it is disabled. Public RPCs often refuse calls such as
+// net_peerCount with HTTP 403 or -32000 rather than -32601.
+const optionalCheckMaxFailures = 3
+
+// catchupActiveLag is how many blocks the tracker may trail the head at the
+// start of a poll and still count as live rather than catching up.
+const catchupActiveLag = 5
+
+// activeWindow is the number of most recent processed heights covered by
+// validator_active_blocks_last_100.
+const activeWindow = 100
+
+// errNotReady is returned when the RPC answers with a null result, which
+// happens for heights the node has not produced proofs or validator info for
+// yet. Such heights are retried on the next poll instead of counted as misses.
+var errNotReady = errors.New("rpc result not available yet")
+
 type rpcResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
-	ID      int             `json:"id"`
+	ID      json.RawMessage `json:"id"`
 	Result  json.RawMessage `json:"result"`
 	Error   *rpcError       `json:"error,omitempty"`
 }
@@ -42,6 +148,28 @@ type rpcError struct {
 	Message string `json:"message"`
 }
 
+// rpcMethodNotFound is the JSON-RPC error code for an unsupported method.
+const rpcMethodNotFound = -32601
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error: %d %s", e.Code, e.Message)
+}
+
+func isMethodNotFound(err error) bool {
+	var rerr *rpcError
+	return errors.As(err, &rerr) && rerr.Code == rpcMethodNotFound
+}
+
+// isCanceled reports whether err comes from the caller's context ending. A
+// request that hit -rpc-timeout also matches context.DeadlineExceeded, but is
+// wrapped in ErrTransport and remains an ordinary fetch failure.
+func isCanceled(err error) bool {
+	if errors.Is(err, ErrTransport) {
+		return false
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
 type ValidatorSetInfo struct {
 	BlsKey      string `json:"blsKey"`
 	IdentityKey string `json:"identityKey"`
@@ -65,12 +193,61 @@ func NewBlockTracker(cfg BlockTrackerConfig) (*BlockTracker, error) {
 	if cfg.RPCURL == "" {
 		return nil, fmt.Errorf("rpc url is required")
 	}
-	if cfg.CheckBlockProof && strings.TrimSpace(cfg.MyBlsKey) == "" {
-		return nil, fmt.Errorf("my bls key is required when check block proof is enabled")
+	tracked := newTrackedValidators(cfg)
+	if cfg.CheckBlockProof && len(tracked) == 0 {
+		return nil, fmt.Errorf("my bls key, identity key or validator id is required when check block proof is enabled")
+	}
+	if cfg.CheckBlockProof && tracked[0].blsKey == "" && !cfg.CheckValidatorSet {
+		return nil, fmt.Errorf("check validator set must be enabled to resolve the bls key from identity key or validator id")
 	}
 	if cfg.PollInterval <= 0 {
 		cfg.PollInterval = 5 * time.Second
 	}
+	if cfg.RPCTimeout <= 0 {
+		cfg.RPCTimeout = 30 * time.Second
+	}
+	if cfg.CatchupConcurrency <= 0 {
+		cfg.CatchupConcurrency = 1
+	}
+	if cfg.BalancePollInterval <= 0 {
+		cfg.BalancePollInterval = time.Minute
+	}
+	if cfg.MaxBackfill == 0 {
+		cfg.MaxBackfill = defaultMaxBackfill
+	}
+	if cfg.MethodBlockNumber == "" {
+		cfg.MethodBlockNumber = "eth_blockNumber"
+	}
+	if cfg.MethodBlockProof == "" {
+		cfg.MethodBlockProof = "debug_getBlockProof"
+	}
+	if cfg.MethodValidatorInfo == "" {
+		cfg.MethodValidatorInfo = "debug_getValidatorInfo"
+	}
+	var fromHeight uint64
+	switch from := strings.ToLower(strings.TrimSpace(cfg.FromHeight)); from {
+	case "", "latest":
+	case "earliest":
+		// genesis carries no block proof, start from the first produced block
+		fromHeight = 1
+	default:
+		h, _, err := parseHeight(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from height: %w", err)
+		}
+		fromHeight = max(h, 1)
+	}
+	balanceTag, err := parseBlockTag(cfg.BalanceBlockTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance block tag: %w", err)
+	}
+	cfg.BalanceBlockTag = balanceTag
+	if cfg.MinBalanceETH < 0 {
+		return nil, fmt.Errorf("invalid min balance: %v", cfg.MinBalanceETH)
+	}
+	if cfg.TokenDecimals < 0 {
+		return nil, fmt.Errorf("invalid token decimals: %d", cfg.TokenDecimals)
+	}
 	if cfg.Output == nil {
 		cfg.Output = os.Stdout
 	}
@@ -78,110 +255,588 @@ func NewBlockTracker(cfg BlockTrackerConfig) (*BlockTracker, error) {
 	// address validation + normalization
 	addr := strings.TrimSpace(cfg.MyAddress)
 	if addr != "" {
-		addrLower := strings.ToLower(addr)
-		if !strings.HasPrefix(addrLower, "0x") || len(addrLower) != 42 {
-			return nil, fmt.Errorf("invalid my-address: expected 0x + 40 hex chars")
+		var err error
+		if addr, err = normalizeAddress(addr); err != nil {
+			return nil, fmt.Errorf("invalid my-address: %w", err)
+		}
+	}
+	var tokens []string
+	for _, t := range cfg.TokenContracts {
+		t, err := normalizeAddress(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token contract: %w", err)
 		}
-		addr = addrLower
+		tokens = append(tokens, t)
+	}
+	if len(tokens) > 0 && addr == "" {
+		return nil, fmt.Errorf("my address is required when token contracts are set")
+	}
+	if cfg.OwnBlocksOnly && addr == "" {
+		return nil, fmt.Errorf("my address is required when own blocks only is enabled")
+	}
+
+	rpc, err := newRPCClient(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	m := &BlockTracker{
 		cfg:           cfg,
-		normalizedKey: normalizeBlsKey(cfg.MyBlsKey),
+		rpc:           rpc,
+		tracked:       tracked,
+		byBlsKey:      make(map[string]*trackedValidator),
 		address:       addr,
+		tokens:        tokens,
+		tokenDecimals: make(map[string]int),
+		tokenFailures: make(map[string]int),
+		fromHeight:    fromHeight,
+		probes:        newProbes(cfg.Probes),
+	}
+	for _, tv := range tracked {
+		if tv.blsKey != "" {
+			m.byBlsKey[tv.blsKey] = tv
+		}
 	}
 	return m, nil
 }
 
 func (m *BlockTracker) Start(ctx context.Context) error {
-	latestHex, err := fetchBlockNumber(ctx, m.cfg.RPCURL)
-	if err != nil {
-		return fmt.Errorf("fetch latest block number failed: %w", err)
-	}
-	lastChecked, _, err := parseHeight(latestHex)
-	if err != nil {
-		return fmt.Errorf("parse latest block number failed: %w", err)
-	}
-	if lastChecked > 0 {
-		lastChecked--
+	// a restarted tracker resumes after the last applied height instead of
+	// backfilling again and counting those heights twice, or skipping the
+	// heights produced while it was down
+	lastChecked := m.lastChecked.Load()
+	if m.started {
+		fmt.Fprintf(m.cfg.Output, "RPC: %s resume from height: %d\n", m.rpc.endpoint, lastChecked+1)
+	} else {
+		latestHex, err := m.preflight(ctx)
+		if err != nil {
+			return err
+		}
+		lastChecked, _, err = parseHeight(latestHex)
+		if err != nil {
+			return fmt.Errorf("parse latest block number failed: %w", err)
+		}
+		lastChecked = m.confirmed(lastChecked)
+		if m.fromHeight > 0 {
+			from := min(m.fromHeight, lastChecked)
+			if lastChecked-from > m.cfg.MaxBackfill {
+				fmt.Fprintf(m.cfg.Output, "backfill from height %d exceeds max backfill of %d blocks, starting at %d\n", from, m.cfg.MaxBackfill, lastChecked-m.cfg.MaxBackfill)
+				from = lastChecked - m.cfg.MaxBackfill
+			}
+			lastChecked = from
+		}
+		if lastChecked > 0 {
+			lastChecked--
+		}
+		fmt.Fprintf(m.cfg.Output, "RPC: %s start from height: %d\n", m.rpc.endpoint, lastChecked+1)
+		RPCIDMismatchTotal.WithLabelValues(m.rpc.endpoint)
+		for _, tv := range m.tracked {
+			if tv.blsKey != "" {
+				KeyInfo.WithLabelValues("0x"+tv.blsKey, m.address).Set(1)
+			}
+		}
+		m.lastChecked.Store(lastChecked)
+		m.started = true
 	}
-	fmt.Fprintf(m.cfg.Output, "RPC: %s start from height: %d\n", m.cfg.RPCURL, lastChecked+1)
 
-	var lastVoteInclusionTs int64
-	var lastActiveTs int64
+	// balances change slowly, so they are refreshed on their own cadence
+	// instead of on every block poll.
+	if err := m.updateBalances(ctx); err != nil {
+		return err
+	}
+	balanceTicker := time.NewTicker(m.cfg.BalancePollInterval)
+	defer balanceTicker.Stop()
 
 	for {
-		latestHex, err := fetchBlockNumber(ctx, m.cfg.RPCURL)
+		busySince := time.Now()
+		latestHex, err := fetchBlockNumber(ctx, m.rpc, m.cfg.MethodBlockNumber)
 		if err != nil {
 			return fmt.Errorf("fetch latest block number failed: %w", err)
 		}
+		RPCLastSuccessTimestamp.WithLabelValues(m.rpc.endpoint).Set(float64(time.Now().Unix()))
+		m.lastPoll.Store(time.Now().UnixNano())
 		latest, _, err := parseHeight(latestHex)
 		if err != nil {
 			return fmt.Errorf("parse latest block number failed: %w", err)
 		}
+		ChainHeadHeight.Set(float64(latest))
 
-		// address balance (ETH) once per poll tick
-		if m.address != "" {
-			eth, err := fetchBalanceETH(ctx, m.cfg.RPCURL, m.address)
-			if err != nil {
-				return fmt.Errorf("fetch balance failed: %w", err)
+		if err := m.updateBlockAge(ctx, latest); err != nil {
+			return err
+		}
+		if err := m.updatePeerCount(ctx); err != nil {
+			return err
+		}
+		if err := m.updateSyncing(ctx); err != nil {
+			return err
+		}
+		if err := m.runProbes(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-balanceTicker.C:
+			if err := m.updateBalances(ctx); err != nil {
+				return err
 			}
-			AddressBalanceETH.WithLabelValues(strings.ToLower(m.address)).Set(eth)
+		default:
 		}
 
-		if latest <= lastChecked {
-			if err := sleepWithContext(ctx, m.cfg.PollInterval); err != nil {
+		target := m.confirmed(latest)
+		if target > lastChecked+catchupActiveLag {
+			CatchupActive.Set(1)
+		} else {
+			CatchupActive.Set(0)
+		}
+		if target <= lastChecked {
+			if err := m.pollSleep(ctx, busySince); err != nil {
 				return err
 			}
 			continue
 		}
 
-		for h := lastChecked + 1; h <= latest; h++ {
-			heightHex := fmt.Sprintf("0x%x", h)
-
-			if m.cfg.CheckBlockProof {
-				bp, err := fetchBlockProof(ctx, m.cfg.RPCURL, heightHex)
+		from := lastChecked
+	catchup:
+		for lastChecked < target {
+			to := min(lastChecked+uint64(m.cfg.CatchupConcurrency), target)
+			results := m.fetchHeights(ctx, lastChecked+1, to)
+			// a cancelled window fails every fetch; stop instead of
+			// skipping or counting those heights
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for _, res := range results {
+				ready, err := m.applyHeight(res)
 				if err != nil {
-					return fmt.Errorf("fetch block proof failed (height=%s): %w", heightHex, err)
+					return err
 				}
-				found := false
-				for _, pk := range bp.SignedBlsKeys {
-					if normalizeBlsKey(pk) == m.normalizedKey {
-						found = true
-						break
-					}
+				if !ready {
+					break catchup
 				}
-				if found {
-					lastVoteInclusionTs = time.Now().Unix()
-					VoteInclusionTotal.Inc()
-					VoteInclusionTimestamp.Set(float64(lastVoteInclusionTs))
+				CatchupRemaining.Set(float64(target - res.height))
+				if (res.height-from)%catchupLogEvery == 0 {
+					fmt.Fprintf(m.cfg.Output, "catch-up: processed height %d, %d blocks remaining\n", res.height, target-res.height)
 				}
+				lastChecked = res.height
+				m.lastChecked.Store(lastChecked)
+				// a long catch-up is progress too; keep the watchdog fed
+				m.lastPoll.Store(time.Now().UnixNano())
 			}
+		}
 
-			if m.cfg.CheckValidatorSet {
-				validators, err := fetchValidators(ctx, m.cfg.RPCURL, heightHex)
-				if err != nil {
-					return fmt.Errorf("fetch validators failed (height=%s): %w", heightHex, err)
-				}
-				found := false
-				for _, v := range validators {
-					if normalizeBlsKey(v.BlsKey) == m.normalizedKey {
-						found = true
-					}
+		if err := m.pollSleep(ctx, busySince); err != nil {
+			return err
+		}
+	}
+}
+
+// pollSleep waits for the next poll, counting the time since busySince as
+// busy and the wait itself as idle.
+func (m *BlockTracker) pollSleep(ctx context.Context, busySince time.Time) error {
+	start := time.Now()
+	PollBusySecondsTotal.Add(start.Sub(busySince).Seconds())
+	err := sleepWithContext(ctx, withJitter(m.cfg.PollInterval, m.cfg.PollJitter))
+	PollIdleSecondsTotal.Add(time.Since(start).Seconds())
+	return err
+}
+
+// LastPoll returns when the tracker last fetched the latest block number or
+// applied a height during catch-up, or the zero time before the first poll.
+func (m *BlockTracker) LastPoll() time.Time {
+	ns := m.lastPoll.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// DumpState writes a human-readable summary of the tracker's progress to w,
+// for on-demand debugging of a running exporter.
+func (m *BlockTracker) DumpState(w io.Writer) {
+	labels := make([]string, 0, len(m.tracked))
+	for _, tv := range m.tracked {
+		labels = append(labels, tv.label)
+	}
+	lastPoll := "never"
+	if t := m.LastPoll(); !t.IsZero() {
+		lastPoll = fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), time.Since(t).Round(time.Second))
+	}
+	fmt.Fprintf(w, "tracker: rpc=%s last_checked=%d last_poll=%s keys=[%s]\n",
+		m.rpc.endpoint, m.lastChecked.Load(), lastPoll, strings.Join(labels, ","))
+}
+
+// confirmed returns the highest height to process for the given head, which
+// trails it by ConfirmationDepth so proofs of the newest blocks can settle.
+func (m *BlockTracker) confirmed(latest uint64) uint64 {
+	if latest < m.cfg.ConfirmationDepth {
+		return 0
+	}
+	return latest - m.cfg.ConfirmationDepth
+}
+
+// preflight fetches the latest block number with a bounded number of attempts
+// instead of the endless retries of rpcPost, so an unreachable RPC is reported
+// at startup rather than retried silently.
+func (m *BlockTracker) preflight(ctx context.Context) (string, error) {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		raw, err := rpcAttempt(ctx, m.rpc, m.cfg.MethodBlockNumber, []interface{}{})
+		if err == nil {
+			hexStr, err := unmarshalQuantity(raw)
+			if err != nil {
+				return "", fmt.Errorf("parse %s result from RPC at %s: %w", m.cfg.MethodBlockNumber, m.rpc.endpoint, err)
+			}
+			return hexStr, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		RPCRequestErrorsTotal.WithLabelValues(m.rpc.endpoint, m.cfg.MethodBlockNumber, rpcErrorKind(err)).Inc()
+		err = connectError(m.rpc.endpoint, m.cfg.MethodBlockNumber, err)
+		if attempt == rpcPreflightAttempts {
+			return "", err
+		}
+		fmt.Fprintf(m.cfg.Output, "warning: %v, retrying in %s (attempt %d/%d)\n", err, delay, attempt, rpcPreflightAttempts)
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return "", err
+		}
+		delay *= 2
+	}
+}
+
+// connectError describes a failed startup request by whether the RPC could be
+// reached at all, unwrapping the URL error so the dial or DNS cause is visible.
+func connectError(endpoint, method string, err error) error {
+	if !errors.Is(err, ErrTransport) {
+		return fmt.Errorf("RPC at %s rejected %s: %w", endpoint, method, err)
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	return fmt.Errorf("cannot connect to RPC at %s: %w", endpoint, err)
+}
+
+// heightResult holds everything fetched for one height. Heights are fetched
+// concurrently during catch-up but always applied to metrics in height order.
+type heightResult struct {
+	height            uint64
+	ownBlock          bool
+	headerErr         error
+	proof             *BlockProof
+	proofErr          error
+	checkedValidators bool
+	validators        []ValidatorSetInfo
+	validatorsErr     error
+	elapsed           time.Duration
+}
+
+func (m *BlockTracker) fetchHeight(ctx context.Context, h uint64) heightResult {
+	start := time.Now()
+	res := heightResult{height: h}
+	heightHex := fmt.Sprintf("0x%x", h)
+	attempts := 0
+	if m.cfg.SkipFailedHeights {
+		attempts = heightFetchAttempts
+	}
+	fetchProof := m.cfg.CheckBlockProof
+	if fetchProof && m.cfg.OwnBlocksOnly {
+		var miner string
+		miner, res.headerErr = fetchBlockMiner(ctx, m.rpc, heightHex)
+		res.ownBlock = res.headerErr == nil && miner == m.address
+		fetchProof = res.ownBlock
+	}
+	if fetchProof {
+		res.proof, res.proofErr = fetchBlockProof(ctx, m.rpc, m.cfg.MethodBlockProof, heightHex, attempts)
+	}
+	if m.cfg.CheckValidatorSet {
+		res.checkedValidators = true
+		res.validators, res.validatorsErr = fetchValidators(ctx, m.rpc, m.cfg.MethodValidatorInfo, heightHex, attempts)
+	}
+	res.elapsed = time.Since(start)
+	return res
+}
+
+// fetchHeights fetches heights from..to (inclusive) in parallel and returns
+// the results ordered by height.
+func (m *BlockTracker) fetchHeights(ctx context.Context, from, to uint64) []heightResult {
+	results := make([]heightResult, to-from+1)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = m.fetchHeight(ctx, from+uint64(i))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// applyHeight updates the metrics for a fetched height. It reports false when
+// the height is not ready yet, in which case nothing was counted and the height
+// is retried on the next poll.
+func (m *BlockTracker) applyHeight(res heightResult) (bool, error) {
+	for _, err := range []error{res.headerErr, res.proofErr, res.validatorsErr} {
+		if isCanceled(err) {
+			return false, err
+		}
+	}
+	if errors.Is(res.headerErr, errNotReady) || errors.Is(res.proofErr, errNotReady) || errors.Is(res.validatorsErr, errNotReady) {
+		return false, nil
+	}
+	if res.headerErr != nil {
+		BlockFetchErrorsTotal.WithLabelValues("eth_getBlockByNumber").Inc()
+		return m.skipHeight(res.height, fmt.Errorf("fetch block header failed (height=0x%x): %w", res.height, res.headerErr))
+	}
+	if isMethodNotFound(res.proofErr) {
+		if m.cfg.CheckBlockProof {
+			fmt.Fprintf(m.cfg.Output, "warning: %s is not supported by the RPC, disabling block proof check: %v\n", m.cfg.MethodBlockProof, res.proofErr)
+			m.cfg.CheckBlockProof = false
+		}
+		res.proofErr = nil
+	}
+	if res.proofErr != nil {
+		BlockFetchErrorsTotal.WithLabelValues(m.cfg.MethodBlockProof).Inc()
+		return m.skipHeight(res.height, fmt.Errorf("fetch block proof failed (height=0x%x): %w", res.height, res.proofErr))
+	}
+	if isMethodNotFound(res.validatorsErr) {
+		if m.cfg.CheckValidatorSet {
+			fmt.Fprintf(m.cfg.Output, "warning: %s is not supported by the RPC, disabling validator set check: %v\n", m.cfg.MethodValidatorInfo, res.validatorsErr)
+			m.cfg.CheckValidatorSet = false
+		}
+		res.checkedValidators = false
+		res.validatorsErr = nil
+	}
+	if res.validatorsErr != nil {
+		BlockFetchErrorsTotal.WithLabelValues(m.cfg.MethodValidatorInfo).Inc()
+		return m.skipHeight(res.height, fmt.Errorf("fetch validators failed (height=0x%x): %w", res.height, res.validatorsErr))
+	}
+
+	if res.checkedValidators {
+		for _, tv := range m.tracked {
+			tv.active = false
+		}
+		for i := range res.validators {
+			for _, tv := range m.tracked {
+				if !tv.active && tv.matches(res.validators[i]) {
+					tv.active = true
+					m.learn(tv, res.validators[i])
 				}
-				if found {
-					lastActiveTs = time.Now().Unix()
-					ActiveTotal.Inc()
-					ActiveTimestamp.Set(float64(lastActiveTs))
+			}
+		}
+		now := m.timestamp(time.Now())
+		for _, tv := range m.tracked {
+			if tv.active {
+				ActiveTotal.WithLabelValues(tv.label).Inc()
+				ActiveTimestamp.WithLabelValues(tv.label).Set(now)
+				IsActive.WithLabelValues(tv.label).Set(1)
+			} else {
+				IsActive.WithLabelValues(tv.label).Set(0)
+			}
+			ActiveBlocksWindow.WithLabelValues(tv.label).Set(float64(tv.recordActive()))
+		}
+	}
+
+	if res.ownBlock {
+		OwnBlocksTotal.Inc()
+	}
+	if res.proof != nil {
+		for _, tv := range m.tracked {
+			tv.voted = false
+		}
+		for _, pk := range res.proof.SignedBlsKeys {
+			if tv := m.signer(pk); tv != nil {
+				tv.voted = true
+			}
+		}
+		now := m.timestamp(time.Now())
+		for _, tv := range m.tracked {
+			if tv.voted {
+				VoteInclusionTotal.WithLabelValues(tv.label).Inc()
+				VoteInclusionTimestamp.WithLabelValues(tv.label).Set(now)
+				VoteIncluded.WithLabelValues(tv.label).Set(1)
+				if res.ownBlock {
+					OwnBlockVoteInclusionTotal.WithLabelValues(tv.label).Inc()
 				}
+			} else {
+				VoteIncluded.WithLabelValues(tv.label).Set(0)
 			}
 		}
-		lastChecked = latest
+	}
 
-		if err := sleepWithContext(ctx, m.cfg.PollInterval); err != nil {
-			return err
+	// both come from the same height, so the ratio is the block's participation
+	if res.proof != nil && res.checkedValidators && len(res.validators) > 0 {
+		BlockSignatureRatio.Set(float64(len(res.proof.SignedBlsKeys)) / float64(len(res.validators)))
+	}
+
+	if res.proof != nil && res.proof.BlockProofHash != "" {
+		// keep a single series, the hash of the latest proof
+		LastProcessedBlockHash.Reset()
+		LastProcessedBlockHash.WithLabelValues(strings.ToLower(res.proof.BlockProofHash)).Set(1)
+	}
+	LastProcessedBlock.Set(float64(res.height))
+
+	BlockProcessDuration.Observe(res.elapsed.Seconds())
+	BlocksProcessedTotal.Inc()
+	return true, nil
+}
+
+// timestamp returns t for the vote and active timestamp gauges, in Unix
+// seconds or, with TimestampMillis, milliseconds.
+func (m *BlockTracker) timestamp(t time.Time) float64 {
+	if m.cfg.TimestampMillis {
+		return float64(t.UnixMilli())
+	}
+	return float64(t.Unix())
+}
+
+// skipHeight decides what a failed fetch of height does: with
+// SkipFailedHeights the height is logged and skipped without touching the
+// vote and active metrics, so it never counts as a miss, otherwise err stops
+// the tracker. A cancellation is never a failed fetch and always stops it.
+func (m *BlockTracker) skipHeight(height uint64, err error) (bool, error) {
+	if !m.cfg.SkipFailedHeights || isCanceled(err) {
+		return false, err
+	}
+	fmt.Fprintf(m.cfg.Output, "warning: skipping height %d: %v\n", height, err)
+	BlocksSkippedTotal.Inc()
+	return true, nil
+}
+
+// updateBlockAge refreshes the head block timestamp whenever the head moves and
+// reports how long ago it was produced. A stalled chain keeps the age growing
+// even while the RPC keeps answering with the same height.
+func (m *BlockTracker) updateBlockAge(ctx context.Context, latest uint64) error {
+	if !m.cfg.CheckBlockTime {
+		return nil
+	}
+	if latest != m.headHeight || m.headTimestamp == 0 {
+		ts, err := fetchBlockTimestamp(ctx, m.rpc, fmt.Sprintf("0x%x", latest))
+		if errors.Is(err, errNotReady) {
+			return nil
+		}
+		if isMethodNotFound(err) {
+			fmt.Fprintf(m.cfg.Output, "warning: eth_getBlockByNumber is not supported by the RPC, disabling block time check: %v\n", err)
+			m.cfg.CheckBlockTime = false
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("fetch block timestamp failed (height=%d): %w", latest, err)
+		}
+		m.headHeight = latest
+		m.headTimestamp = ts
+	}
+	SecondsSinceLastBlock.Set(float64(time.Now().Unix() - m.headTimestamp))
+	return nil
+}
+
+func (m *BlockTracker) updatePeerCount(ctx context.Context) error {
+	if !m.cfg.CheckPeers {
+		return nil
+	}
+	peers, err := fetchPeerCount(ctx, m.rpc, optionalCheckAttempts)
+	if isMethodNotFound(err) {
+		fmt.Fprintf(m.cfg.Output, "warning: net_peerCount is not supported by the RPC, disabling peer check: %v\n", err)
+		m.cfg.CheckPeers = false
+		return nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if m.optionalCheckFailed("peer check", &m.peerFailures, err) {
+			m.cfg.CheckPeers = false
+		}
+		return nil
+	}
+	m.peerFailures = 0
+	PeerCount.Set(float64(peers))
+	return nil
+}
+
+// optionalCheckFailed warns about a failed optional check and reports whether
+// it has now failed optionalCheckMaxFailures polls in a row and should be
+// disabled.
+func (m *BlockTracker) optionalCheckFailed(name string, failures *int, err error) bool {
+	*failures++
+	if *failures < optionalCheckMaxFailures {
+		fmt.Fprintf(m.cfg.Output, "warning: %s failed (%d/%d): %v\n", name, *failures, optionalCheckMaxFailures, err)
+		return false
+	}
+	fmt.Fprintf(m.cfg.Output, "warning: %s failed %d polls in a row, disabling it: %v\n", name, *failures, err)
+	return true
+}
+
+func (m *BlockTracker) updateSyncing(ctx context.Context) error {
+	if !m.cfg.CheckSyncing {
+		return nil
+	}
+	status, err := fetchSyncing(ctx, m.rpc, optionalCheckAttempts)
+	if isMethodNotFound(err) {
+		fmt.Fprintf(m.cfg.Output, "warning: eth_syncing is not supported by the RPC, disabling syncing check: %v\n", err)
+		m.cfg.CheckSyncing = false
+		return nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if m.optionalCheckFailed("syncing check", &m.syncFailures, err) {
+			m.cfg.CheckSyncing = false
+		}
+		return nil
+	}
+	m.syncFailures = 0
+	if status == nil {
+		NodeIsSyncing.Set(0)
+		NodeSyncHighestBlock.Set(0)
+		return nil
+	}
+	NodeIsSyncing.Set(1)
+	// a bare true carries no progress, keep the last known highest block
+	if status.HighestBlock > 0 {
+		NodeSyncHighestBlock.Set(float64(status.HighestBlock))
+	}
+	return nil
+}
+
+func (m *BlockTracker) updateBalances(ctx context.Context) error {
+	if !m.cfg.CheckBalance || m.address == "" {
+		return nil
+	}
+	if err := m.updateAddressBalance(ctx); err != nil {
+		return err
+	}
+	return m.updateTokenBalances(ctx)
+}
+
+// updateAddressBalance refreshes the balance of the tracked address. An RPC
+// lacking the state for -balance-block-tag answers with a persistent error,
+// so failures are bounded and eventually disable the check instead of
+// stalling block processing.
+func (m *BlockTracker) updateAddressBalance(ctx context.Context) error {
+	if m.balanceOff {
+		return nil
+	}
+	eth, err := fetchBalanceETH(ctx, m.rpc, m.address, m.cfg.BalanceBlockTag, m.cfg.TokenDecimals, optionalCheckAttempts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.balanceOff = isMethodNotFound(err) || m.optionalCheckFailed("balance check", &m.balanceFailures, err)
+		return nil
+	}
+	m.balanceFailures = 0
+	AddressBalanceETH.WithLabelValues(m.address).Set(eth)
+	if m.cfg.MinBalanceETH > 0 {
+		below := 0.0
+		if eth < m.cfg.MinBalanceETH {
+			below = 1
 		}
+		BalanceBelowThreshold.WithLabelValues(m.address).Set(below)
 	}
+	return nil
 }
 
 func sleepWithContext(ctx context.Context, d time.Duration) error {
@@ -198,6 +853,19 @@ func sleepWithContext(ctx context.Context, d time.Duration) error {
 	}
 }
 
+// withJitter shifts d by a random amount in [-j, j] so that many exporters
+// started together do not poll the RPC in lockstep.
+func withJitter(d, j time.Duration) time.Duration {
+	if j <= 0 {
+		return d
+	}
+	d += time.Duration(rand.Int64N(int64(2*j)+1)) - j
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
 func trim0x(s string) string {
 	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
 		return s[2:]
@@ -205,20 +873,106 @@ func trim0x(s string) string {
 	return s
 }
 
-func rpcPost(ctx context.Context, url, method string, params interface{}) (json.RawMessage, error) {
-	const rpcRetryBaseDelay = 200 * time.Millisecond
-	const rpcRetryMaxDelay = 2 * time.Second
-
-	reqBody := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  method,
-		"params":  params,
+// RPCEndpoint returns the scheme and host of the RPC URL rawURL, the form in
+// which the RPC is logged and labeled, so credentials or API keys in the URL
+// are never printed.
+func RPCEndpoint(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "<invalid url>"
 	}
-	b, err := json.Marshal(reqBody)
+	return u.Scheme + "://" + u.Host
+}
+
+func newRPCClient(cfg BlockTrackerConfig) (*rpcClient, error) {
+	rpcURL, err := url.Parse(cfg.RPCURL)
 	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+		return nil, fmt.Errorf("invalid rpc url: %w", err)
+	}
+	if rpcURL.Host == "" {
+		return nil, fmt.Errorf("invalid rpc url %q: missing host", cfg.RPCURL)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.RPCProxy != "" {
+		proxyURL, err := url.Parse(cfg.RPCProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpc proxy: %w", err)
+		}
+		// net/http dials socks5 proxies itself, so a SOCKS URL needs no
+		// separate dialer.
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid rpc proxy: unsupported scheme %q", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.RPCCACert != "" || cfg.RPCInsecureSkipVerify {
+		tlsCfg := &tls.Config{InsecureSkipVerify: cfg.RPCInsecureSkipVerify}
+		if cfg.RPCCACert != "" {
+			pem, err := os.ReadFile(cfg.RPCCACert)
+			if err != nil {
+				return nil, fmt.Errorf("read rpc ca cert: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("rpc ca cert %s: no PEM certificates found", cfg.RPCCACert)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+	// keep enough idle connections for every catch-up worker plus the
+	// per-tick calls, so parallel fetches reuse connections instead of
+	// redialing the RPC.
+	transport.MaxIdleConnsPerHost = cfg.CatchupConcurrency + 4
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	return &rpcClient{
+		url:      cfg.RPCURL,
+		endpoint: RPCEndpoint(cfg.RPCURL),
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.RPCTimeout,
+		},
+		limiter: newRateLimiter(cfg.RPCRateLimit),
+	}, nil
+}
+
+// Error kinds returned by rpcPost, so callers and metrics can tell flaky
+// networking apart from an RPC that answers but rejects or garbles requests.
+var (
+	ErrTransport   = errors.New("rpc transport error")
+	ErrRPCProtocol = errors.New("rpc protocol error")
+	ErrDecode      = errors.New("rpc decode error")
+)
+
+func (e *rpcError) Is(target error) bool {
+	return target == ErrRPCProtocol
+}
+
+func rpcErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrRPCProtocol):
+		return "protocol"
+	case errors.Is(err, ErrDecode):
+		return "decode"
+	default:
+		return "transport"
 	}
+}
+
+func rpcPost(ctx context.Context, c *rpcClient, method string, params interface{}) (json.RawMessage, error) {
+	return rpcPostAttempts(ctx, c, method, params, 0)
+}
+
+// rpcPostAttempts is rpcPost giving up after maxAttempts failed attempts, or
+// never when maxAttempts is 0.
+func rpcPostAttempts(ctx context.Context, c *rpcClient, method string, params interface{}, maxAttempts int) (json.RawMessage, error) {
+	const rpcRetryBaseDelay = 200 * time.Millisecond
+	const rpcRetryMaxDelay = 2 * time.Second
 
 	for attempt := 0; ; attempt++ {
 		select {
@@ -227,35 +981,25 @@ func rpcPost(ctx context.Context, url, method string, params interface{}) (json.
 		default:
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
-		if err != nil {
-			return nil, fmt.Errorf("new request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := http.DefaultClient.Do(req)
+		result, err := rpcAttempt(ctx, c, method, params)
 		if err == nil {
-			body, readErr := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			if readErr != nil {
-				err = fmt.Errorf("read response body: %w", readErr)
-			} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				err = fmt.Errorf("http status: %s", resp.Status)
-			} else {
-				var r rpcResponse
-				if unmarshalErr := json.Unmarshal(body, &r); unmarshalErr != nil {
-					err = fmt.Errorf("unmarshal rpc response: %w (body=%s)", unmarshalErr, string(body))
-				} else if r.Error != nil {
-					err = fmt.Errorf("rpc error: %d %s", r.Error.Code, r.Error.Message)
-				} else {
-					return r.Result, nil
-				}
-			}
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		RPCRequestErrorsTotal.WithLabelValues(c.endpoint, method, rpcErrorKind(err)).Inc()
+		// an unsupported method will not start working on retry
+		if isMethodNotFound(err) {
+			return nil, err
+		}
+		if maxAttempts > 0 && attempt+1 >= maxAttempts {
+			return nil, err
 		}
 
-		backoff := rpcRetryBaseDelay * (1 << attempt)
-		if backoff > rpcRetryMaxDelay {
-			backoff = rpcRetryMaxDelay
+		backoff := rpcRetryMaxDelay
+		if attempt < 8 {
+			backoff = min(rpcRetryBaseDelay*(1<<attempt), rpcRetryMaxDelay)
 		}
 		if err := sleepWithContext(ctx, backoff); err != nil {
 			return nil, err
@@ -263,26 +1007,115 @@ func rpcPost(ctx context.Context, url, method string, params interface{}) (json.
 	}
 }
 
-func fetchBlockNumber(ctx context.Context, rpcURL string) (string, error) {
-	resultRaw, err := rpcPost(ctx, rpcURL, "eth_blockNumber", []interface{}{})
+func rpcRequestBody(id uint64, method string, params interface{}) ([]byte, error) {
+	reqBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}
+	b, err := json.Marshal(reqBody)
 	if err != nil {
-		return "0x0", fmt.Errorf("rpc call eth_blockNumber failed: %w", err)
+		return nil, fmt.Errorf("marshal request: %w", err)
 	}
+	return b, nil
+}
 
-	var hexStr string
-	if err := json.Unmarshal(resultRaw, &hexStr); err != nil {
-		return "0x0", fmt.Errorf("parse eth_blockNumber result failed: %w", err)
+// rpcAttempt performs a single JSON-RPC round trip. Errors wrap ErrTransport,
+// ErrDecode or ErrRPCProtocol.
+func rpcAttempt(ctx context.Context, c *rpcClient, method string, params interface{}) (json.RawMessage, error) {
+	id := c.lastID.Add(1)
+	body, err := rpcRequestBody(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	InflightRPCRequests.Inc()
+	defer InflightRPCRequests.Dec()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// the url.Error quotes the full URL; keep its secrets out of logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = c.endpoint
+		}
+		return nil, fmt.Errorf("%w: %w", ErrTransport, err)
+	}
+	respBody, err := readResponseBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read response body: %w", ErrTransport, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: http status: %s", ErrTransport, resp.Status)
+	}
+	var r rpcResponse
+	if err := json.Unmarshal(respBody, &r); err != nil {
+		return nil, fmt.Errorf("%w: unmarshal rpc response: %w (body=%s)", ErrDecode, err, string(respBody))
+	}
+	// JSON-RPC answers a request it could not parse with a null id, so such
+	// an error is the server's verdict on this request, not a mixed-up reply
+	if r.Error != nil && isNullResult(r.ID) {
+		return nil, r.Error
+	}
+	if want := strconv.FormatUint(id, 10); string(bytes.TrimSpace(r.ID)) != want {
+		RPCIDMismatchTotal.WithLabelValues(c.endpoint).Inc()
+		return nil, fmt.Errorf("%w: response id %s does not match request id %s", ErrDecode, r.ID, want)
+	}
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	return r.Result, nil
+}
+
+// readResponseBody reads and closes the response body. The transport only
+// decompresses gzip it asked for itself, so a gateway that gzips regardless
+// of the request is decoded here.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	return io.ReadAll(body)
+}
+
+func fetchBlockNumber(ctx context.Context, c *rpcClient, method string) (string, error) {
+	resultRaw, err := rpcPost(ctx, c, method, []interface{}{})
+	if err != nil {
+		return "0x0", fmt.Errorf("rpc call %s failed: %w", method, err)
+	}
+
+	hexStr, err := unmarshalQuantity(resultRaw)
+	if err != nil {
+		return "0x0", fmt.Errorf("parse %s result failed: %w", method, err)
 	}
 
 	return hexStr, nil
 }
 
-func fetchValidators(ctx context.Context, rpcURL string, height interface{}) ([]ValidatorSetInfo, error) {
-	resultRaw, err := rpcPost(ctx, rpcURL, "debug_getValidatorInfo", []interface{}{height})
+func fetchValidators(ctx context.Context, c *rpcClient, method string, height interface{}, maxAttempts int) ([]ValidatorSetInfo, error) {
+	resultRaw, err := rpcPostAttempts(ctx, c, method, []interface{}{height}, maxAttempts)
 	if err != nil {
 		return nil, err
 	}
 
+	if isNullResult(resultRaw) {
+		return nil, errNotReady
+	}
+
 	var vInfo ValidatorInfo
 	if err := json.Unmarshal(resultRaw, &vInfo); err != nil {
 		return nil, err
@@ -291,11 +1124,14 @@ func fetchValidators(ctx context.Context, rpcURL string, height interface{}) ([]
 	return vInfo.ValidatorSet, nil
 }
 
-func fetchBlockProof(ctx context.Context, rpcURL string, height interface{}) (*BlockProof, error) {
-	resultRaw, err := rpcPost(ctx, rpcURL, "debug_getBlockProof", []interface{}{height})
+func fetchBlockProof(ctx context.Context, c *rpcClient, method string, height interface{}, maxAttempts int) (*BlockProof, error) {
+	resultRaw, err := rpcPostAttempts(ctx, c, method, []interface{}{height}, maxAttempts)
 	if err != nil {
 		return nil, err
 	}
+	if isNullResult(resultRaw) {
+		return nil, errNotReady
+	}
 	var bp BlockProof
 	if err := json.Unmarshal(resultRaw, &bp); err != nil {
 		return nil, fmt.Errorf("parse block proof: %w", err)
@@ -303,28 +1139,196 @@ func fetchBlockProof(ctx context.Context, rpcURL string, height interface{}) (*B
 	return &bp, nil
 }
 
-func fetchBalanceETH(ctx context.Context, rpcURL, address string) (float64, error) {
-	resultRaw, err := rpcPost(ctx, rpcURL, "eth_getBalance", []interface{}{address, "latest"})
+func fetchBlockTimestamp(ctx context.Context, c *rpcClient, height interface{}) (int64, error) {
+	resultRaw, err := rpcPost(ctx, c, "eth_getBlockByNumber", []interface{}{height, false})
 	if err != nil {
-		return 0, fmt.Errorf("rpc call eth_getBalance failed: %w", err)
+		return 0, err
+	}
+	if isNullResult(resultRaw) {
+		return 0, errNotReady
+	}
+	var header struct {
+		Timestamp string `json:"timestamp"`
+	}
+	if err := json.Unmarshal(resultRaw, &header); err != nil {
+		return 0, fmt.Errorf("parse block header: %w", err)
 	}
+	ts, _, err := parseHeight(header.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("parse block timestamp: %w", err)
+	}
+	return int64(ts), nil
+}
 
+// fetchBlockMiner returns the normalized miner (proposer) address from the
+// header of the block at height.
+func fetchBlockMiner(ctx context.Context, c *rpcClient, height interface{}) (string, error) {
+	resultRaw, err := rpcPost(ctx, c, "eth_getBlockByNumber", []interface{}{height, false})
+	if err != nil {
+		return "", err
+	}
+	if isNullResult(resultRaw) {
+		return "", errNotReady
+	}
+	var header struct {
+		Miner string `json:"miner"`
+	}
+	if err := json.Unmarshal(resultRaw, &header); err != nil {
+		return "", fmt.Errorf("parse block header: %w", err)
+	}
+	miner, err := normalizeAddress(header.Miner)
+	if err != nil {
+		return "", fmt.Errorf("parse block miner: %w", err)
+	}
+	return miner, nil
+}
+
+func fetchPeerCount(ctx context.Context, c *rpcClient, maxAttempts int) (uint64, error) {
+	resultRaw, err := rpcPostAttempts(ctx, c, "net_peerCount", []interface{}{}, maxAttempts)
+	if err != nil {
+		return 0, fmt.Errorf("rpc call net_peerCount failed: %w", err)
+	}
 	var hexStr string
 	if err := json.Unmarshal(resultRaw, &hexStr); err != nil {
+		return 0, fmt.Errorf("parse net_peerCount result failed: %w", err)
+	}
+	peers, _, err := parseHeight(hexStr)
+	if err != nil {
+		return 0, fmt.Errorf("parse net_peerCount result failed: %w", err)
+	}
+	return peers, nil
+}
+
+type SyncStatus struct {
+	StartingBlock uint64
+	CurrentBlock  uint64
+	HighestBlock  uint64
+}
+
+// fetchSyncing returns nil when the node reports it is not syncing (eth_syncing
+// returns false) and the sync progress otherwise. A bare true yields a zero
+// SyncStatus, since the node reports no progress.
+func fetchSyncing(ctx context.Context, c *rpcClient, maxAttempts int) (*SyncStatus, error) {
+	resultRaw, err := rpcPostAttempts(ctx, c, "eth_syncing", []interface{}{}, maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("rpc call eth_syncing failed: %w", err)
+	}
+	var syncing bool
+	if err := json.Unmarshal(resultRaw, &syncing); err == nil {
+		if syncing {
+			return &SyncStatus{}, nil
+		}
+		return nil, nil
+	}
+	var raw struct {
+		StartingBlock string `json:"startingBlock"`
+		CurrentBlock  string `json:"currentBlock"`
+		HighestBlock  string `json:"highestBlock"`
+	}
+	if err := json.Unmarshal(resultRaw, &raw); err != nil {
+		return nil, fmt.Errorf("parse eth_syncing result failed: %w", err)
+	}
+	var status SyncStatus
+	for _, f := range []struct {
+		dst *uint64
+		src string
+	}{
+		{&status.StartingBlock, raw.StartingBlock},
+		{&status.CurrentBlock, raw.CurrentBlock},
+		{&status.HighestBlock, raw.HighestBlock},
+	} {
+		if f.src == "" {
+			continue
+		}
+		v, _, err := parseHeight(f.src)
+		if err != nil {
+			return nil, fmt.Errorf("parse eth_syncing result failed: %w", err)
+		}
+		*f.dst = v
+	}
+	return &status, nil
+}
+
+func fetchBalanceETH(ctx context.Context, c *rpcClient, address, blockTag string, decimals, maxAttempts int) (float64, error) {
+	resultRaw, err := rpcPostAttempts(ctx, c, "eth_getBalance", []interface{}{address, blockTag}, maxAttempts)
+	if err != nil {
+		return 0, fmt.Errorf("rpc call eth_getBalance failed: %w", err)
+	}
+
+	hexStr, err := unmarshalQuantity(resultRaw)
+	if err != nil {
 		return 0, fmt.Errorf("parse eth_getBalance result failed: %w", err)
 	}
 
-	wei := new(big.Int)
-	if _, ok := wei.SetString(trim0x(hexStr), 16); !ok {
-		return 0, fmt.Errorf("invalid balance hex: %q", hexStr)
+	wei, err := parseHexBigInt(hexStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid balance hex: %w", err)
 	}
 
 	// convert Wei -> ETH as float64 for Prometheus gauge
-	weiF := new(big.Float).SetPrec(256).SetInt(wei)
-	ethF := new(big.Float).SetPrec(256).Quo(weiF, big.NewFloat(1e18))
+	return scaleDecimals(wei, decimals), nil
+}
+
+func isNullResult(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
+
+// unmarshalQuantity decodes a quantity result as a hex string. Some RPC
+// implementations return a plain JSON number instead of the 0x-prefixed
+// string; such values are converted to hex. A null result is an error rather
+// than an empty quantity.
+func unmarshalQuantity(raw json.RawMessage) (string, error) {
+	if isNullResult(raw) {
+		return "", errors.New("null quantity")
+	}
+	var hexStr string
+	strErr := json.Unmarshal(raw, &hexStr)
+	if strErr == nil {
+		return hexStr, nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(raw, &num); err != nil {
+		return "", strErr
+	}
+	v, ok := new(big.Int).SetString(num.String(), 10)
+	if !ok || v.Sign() < 0 {
+		return "", fmt.Errorf("%s is not a non-negative integer quantity", num)
+	}
+	return "0x" + v.Text(16), nil
+}
+
+// parseStaking parses a validator set staking amount, which RPCs report either
+// as a decimal or as a 0x-prefixed hex string.
+func parseStaking(s string) (*big.Int, error) {
+	digits, base := strings.TrimSpace(s), 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	v, ok := new(big.Int).SetString(digits, base)
+	if !ok || v.Sign() < 0 {
+		return nil, fmt.Errorf("invalid staking amount %q", s)
+	}
+	return v, nil
+}
 
-	eth, _ := ethF.Float64()
-	return eth, nil
+func parseHexBigInt(hexStr string) (*big.Int, error) {
+	v := new(big.Int)
+	if _, ok := v.SetString(trim0x(hexStr), 16); !ok {
+		return nil, fmt.Errorf("%q is not a hex quantity", hexStr)
+	}
+	return v, nil
+}
+
+// scaleDecimals converts an integer token amount into whole units by dividing
+// by 10^decimals, e.g. wei -> ETH for decimals=18.
+func scaleDecimals(v *big.Int, decimals int) float64 {
+	const prec = 256
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	f := new(big.Float).SetPrec(prec).SetInt(v)
+	f.Quo(f, new(big.Float).SetPrec(prec).SetInt(divisor))
+	out, _ := f.Float64()
+	return out
 }
 
 func parseHeight(s string) (uint64, bool, error) {
@@ -339,10 +1343,167 @@ func parseHeight(s string) (uint64, bool, error) {
 	return v, false, nil
 }
 
+// parseBlockTag validates a block parameter: latest (the default when
+// empty), pending, earliest or a height, which is returned as hex quantity.
+func parseBlockTag(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "latest":
+		return "latest", nil
+	case "pending", "earliest":
+		return s, nil
+	}
+	h, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return "", fmt.Errorf("expected latest, pending, earliest or a height, got %q", s)
+	}
+	return fmt.Sprintf("0x%x", h), nil
+}
+
+func normalizeAddress(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !strings.HasPrefix(s, "0x") || len(s) != 42 {
+		return "", fmt.Errorf("expected 0x + 40 hex chars, got %q", s)
+	}
+	return s, nil
+}
+
+// trackedValidator is one validator the tracker reports on, labeled by the
+// key it was configured with. The per-block flags are reset in applyHeight.
+type trackedValidator struct {
+	label       string
+	blsKey      string
+	identityKey string
+	validatorID string
+	learnedID   bool
+	badStaking  bool
+	active      bool
+	voted       bool
+	// recent is a ring of the active flag over the last activeWindow
+	// processed heights, recentActive the number of true entries in it.
+	recent       [activeWindow]bool
+	recentPos    int
+	recentActive int
+}
+
+// recordActive pushes the active flag of the latest height into the ring,
+// dropping the oldest one, and returns the active count in the window.
+func (tv *trackedValidator) recordActive() int {
+	if tv.recent[tv.recentPos] {
+		tv.recentActive--
+	}
+	tv.recent[tv.recentPos] = tv.active
+	if tv.active {
+		tv.recentActive++
+	}
+	tv.recentPos = (tv.recentPos + 1) % activeWindow
+	return tv.recentActive
+}
+
+// newTrackedValidators returns one tracked validator per distinct BLS key. The
+// identity key, else the validator id, is only used when no BLS key is set; its
+// BLS key is then resolved from the validator set.
+func newTrackedValidators(cfg BlockTrackerConfig) []*trackedValidator {
+	var tracked []*trackedValidator
+	seen := make(map[string]bool)
+	for _, k := range append([]string{cfg.MyBlsKey}, cfg.MyBlsKeys...) {
+		key := normalizeBlsKey(k)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tracked = append(tracked, &trackedValidator{label: "0x" + key, blsKey: key})
+	}
+	if len(tracked) > 0 {
+		return tracked
+	}
+	if id := normalizeHexID(cfg.MyIdentityKey); id != "" {
+		return []*trackedValidator{{label: "0x" + id, identityKey: id}}
+	}
+	if id := normalizeHexID(cfg.MyValidatorID); id != "" {
+		return []*trackedValidator{{label: id, validatorID: id}}
+	}
+	return nil
+}
+
+func (tv *trackedValidator) matches(v ValidatorSetInfo) bool {
+	switch {
+	case tv.identityKey != "":
+		return normalizeHexID(v.IdentityKey) == tv.identityKey
+	case tv.validatorID != "":
+		return normalizeHexID(v.ValidatorID) == tv.validatorID
+	}
+	return blsKeyMatches(v.BlsKey, tv.blsKey)
+}
+
+// learn records what the validator set tells about tv: the BLS key to look for
+// in block proofs when tv was configured without one, and its validator id.
+func (m *BlockTracker) learn(tv *trackedValidator, v ValidatorSetInfo) {
+	if tv.identityKey != "" || tv.validatorID != "" {
+		if key := normalizeBlsKey(v.BlsKey); key != "" && key != tv.blsKey {
+			if tv.blsKey != "" {
+				KeyInfo.DeleteLabelValues("0x"+tv.blsKey, m.address)
+			}
+			delete(m.byBlsKey, tv.blsKey)
+			tv.blsKey = key
+			m.byBlsKey[key] = tv
+			KeyInfo.WithLabelValues("0x"+key, m.address).Set(1)
+		}
+	}
+	if !tv.learnedID && v.ValidatorID != "" {
+		ValidatorIDInfo.WithLabelValues(tv.label, v.ValidatorID).Set(1)
+		tv.learnedID = true
+	}
+	stake, err := parseStaking(v.Staking)
+	if err != nil {
+		// warn once per validator, the value repeats on every block
+		if !tv.badStaking {
+			fmt.Fprintf(m.cfg.Output, "warning: validator %s: %v\n", tv.label, err)
+			tv.badStaking = true
+		}
+		return
+	}
+	tv.badStaking = false
+	ValidatorStaking.WithLabelValues(tv.label).Set(scaleDecimals(stake, m.cfg.TokenDecimals))
+}
+
+// normalizeHexID lowercases an identifier and strips its 0x prefix.
+func normalizeHexID(s string) string {
+	return strings.ToLower(trim0x(strings.TrimSpace(s)))
+}
+
 func normalizeBlsKey(s string) string {
-	s = strings.ToLower(trim0x(strings.TrimSpace(s)))
+	return strings.ToLower(trimBlsKey(s))
+}
+
+// trimBlsKey strips the spaces, 0x prefix and any leading bytes beyond the
+// 48-byte key from s without changing its case.
+func trimBlsKey(s string) string {
+	s = trim0x(strings.TrimSpace(s))
 	if len(s) > 96 && len(s)%2 == 0 {
 		s = s[len(s)-96:]
 	}
 	return s
 }
+
+// blsKeyMatches reports whether pk normalizes to the already normalized key
+// without allocating, since it runs for every key of every block in catch-up.
+func blsKeyMatches(pk, normalized string) bool {
+	return strings.EqualFold(trimBlsKey(pk), normalized)
+}
+
+// signer returns the tracked validator signing with pk, or nil. It runs for
+// every signed key of every block in catch-up, so it never allocates: a key
+// already in lower-case hex is looked up directly, any other is compared with
+// each tracked key.
+func (m *BlockTracker) signer(pk string) *trackedValidator {
+	key := trimBlsKey(pk)
+	if isHex(key) {
+		return m.byBlsKey[key]
+	}
+	for _, tv := range m.tracked {
+		if tv.blsKey != "" && strings.EqualFold(key, tv.blsKey) {
+			return tv
+		}
+	}
+	return nil
+}
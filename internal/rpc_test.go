@@ -0,0 +1,562 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newMockRPC starts a JSON-RPC server that answers each request with the body
+// returned by reply for its method, with %s standing in for the request id.
+// It returns a client for the server and a counter of the requests served.
+func newMockRPC(t *testing.T, reply func(method string) string) (*rpcClient, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, reply(req.Method), req.ID)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := newRPCClient(BlockTrackerConfig{RPCURL: srv.URL, RPCTimeout: time.Second, CatchupConcurrency: 1})
+	if err != nil {
+		t.Fatalf("newRPCClient: %v", err)
+	}
+	return c, &calls
+}
+
+func result(raw string) func(string) string {
+	return func(string) string {
+		return `{"jsonrpc":"2.0","id":%s,"result":` + raw + `}`
+	}
+}
+
+func rpcErrorReply(code int, message string) func(string) string {
+	return func(string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%%s,"error":{"code":%d,"message":%q}}`, code, message)
+	}
+}
+
+// malformed answers with a truncated JSON body.
+func malformed(string) string {
+	return `{"jsonrpc":"2.0","id":%s,"result":`
+}
+
+// shortContext bounds calls that rpcPost would otherwise retry forever.
+func shortContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestFetchBlockNumber(t *testing.T) {
+	c, _ := newMockRPC(t, result(`"0x1a2b"`))
+	got, err := fetchBlockNumber(context.Background(), c, "eth_blockNumber")
+	if err != nil {
+		t.Fatalf("fetchBlockNumber: %v", err)
+	}
+	if got != "0x1a2b" {
+		t.Errorf("fetchBlockNumber = %q, want %q", got, "0x1a2b")
+	}
+}
+
+func TestFetchBlockNumberErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(string) string
+	}{
+		{"method not found", rpcErrorReply(rpcMethodNotFound, "the method eth_blockNumber does not exist")},
+		{"null result", result(`null`)},
+		{"not a quantity", result(`{"number":"0x1"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockRPC(t, tt.reply)
+			if got, err := fetchBlockNumber(context.Background(), c, "eth_blockNumber"); err == nil {
+				t.Errorf("fetchBlockNumber = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestFetchBlockNumberMalformedJSON(t *testing.T) {
+	c, calls := newMockRPC(t, malformed)
+	_, err := fetchBlockNumber(shortContext(t), c, "eth_blockNumber")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fetchBlockNumber error = %v, want retries until the deadline", err)
+	}
+	if calls.Load() < 2 {
+		t.Errorf("malformed response was tried %d times, want it retried", calls.Load())
+	}
+}
+
+func TestFetchBlockProof(t *testing.T) {
+	c, _ := newMockRPC(t, result(`{"blockNumber":"0x10","blockProofHash":"0xaa","signedBlsKeys":["0xAB","cd"]}`))
+	bp, err := fetchBlockProof(context.Background(), c, "debug_getBlockProof", "0x10", 1)
+	if err != nil {
+		t.Fatalf("fetchBlockProof: %v", err)
+	}
+	if bp.BlockNumber != "0x10" || len(bp.SignedBlsKeys) != 2 || bp.SignedBlsKeys[0] != "0xAB" {
+		t.Errorf("fetchBlockProof = %+v", bp)
+	}
+}
+
+func TestFetchBlockProofErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(string) string
+		want  error
+	}{
+		{"rpc error", rpcErrorReply(-32000, "proof unavailable"), ErrRPCProtocol},
+		{"malformed json", malformed, ErrDecode},
+		{"wrong shape", result(`{"signedBlsKeys":"0xab"}`), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, calls := newMockRPC(t, tt.reply)
+			_, err := fetchBlockProof(context.Background(), c, "debug_getBlockProof", "0x10", 2)
+			if err == nil {
+				t.Fatal("fetchBlockProof succeeded, want error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("fetchBlockProof error = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && calls.Load() != 2 {
+				t.Errorf("served %d requests, want maxAttempts 2", calls.Load())
+			}
+		})
+	}
+}
+
+func TestFetchValidators(t *testing.T) {
+	c, _ := newMockRPC(t, result(`{"blockNumber":"0x10","validatorSet":[{"blsKey":"0xab","identityKey":"0xcd","staking":"1000","validatorID":"7"}]}`))
+	set, err := fetchValidators(context.Background(), c, "debug_getValidatorInfo", "0x10", 1)
+	if err != nil {
+		t.Fatalf("fetchValidators: %v", err)
+	}
+	want := ValidatorSetInfo{BlsKey: "0xab", IdentityKey: "0xcd", Staking: "1000", ValidatorID: "7"}
+	if len(set) != 1 || set[0] != want {
+		t.Errorf("fetchValidators = %+v, want [%+v]", set, want)
+	}
+}
+
+func TestFetchValidatorsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(string) string
+		want  error
+	}{
+		{"method not found", rpcErrorReply(rpcMethodNotFound, "method not found"), ErrRPCProtocol},
+		{"malformed json", malformed, ErrDecode},
+		{"null result", result(`null`), errNotReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockRPC(t, tt.reply)
+			_, err := fetchValidators(context.Background(), c, "debug_getValidatorInfo", "0x10", 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("fetchValidators error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchBalanceETH(t *testing.T) {
+	// 1.5 ETH in wei
+	c, _ := newMockRPC(t, result(`"0x14d1120d7b160000"`))
+	got, err := fetchBalanceETH(context.Background(), c, "0x01", "latest", 18, 1)
+	if err != nil {
+		t.Fatalf("fetchBalanceETH: %v", err)
+	}
+	if got != 1.5 {
+		t.Errorf("fetchBalanceETH = %v, want 1.5", got)
+	}
+}
+
+func TestFetchBalanceETHErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(string) string
+	}{
+		{"method not found", rpcErrorReply(rpcMethodNotFound, "method not found")},
+		{"null result", result(`null`)},
+		{"not hex", result(`"0xzz"`)},
+		{"malformed json", malformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockRPC(t, tt.reply)
+			if got, err := fetchBalanceETH(context.Background(), c, "0x01", "latest", 18, 1); err == nil {
+				t.Errorf("fetchBalanceETH = %v, want error", got)
+			}
+		})
+	}
+}
+
+func TestRPCAttemptIDMismatch(t *testing.T) {
+	c, _ := newMockRPC(t, func(string) string {
+		return `{"jsonrpc":"2.0","id":999999,"result":"0x1"}%.0s`
+	})
+	_, err := rpcAttempt(context.Background(), c, "eth_blockNumber", []interface{}{})
+	if !errors.Is(err, ErrDecode) {
+		t.Errorf("rpcAttempt error = %v, want %v", err, ErrDecode)
+	}
+}
+
+func TestRPCAttemptNullIDError(t *testing.T) {
+	c, _ := newMockRPC(t, func(string) string {
+		return `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}%.0s`
+	})
+	_, err := rpcAttempt(context.Background(), c, "eth_blockNumber", []interface{}{})
+	var rerr *rpcError
+	if !errors.As(err, &rerr) || rerr.Code != -32700 {
+		t.Errorf("rpcAttempt error = %v, want the rpc error -32700", err)
+	}
+}
+
+func TestRPCAttemptHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	c, err := newRPCClient(BlockTrackerConfig{RPCURL: srv.URL, RPCTimeout: time.Second, CatchupConcurrency: 1})
+	if err != nil {
+		t.Fatalf("newRPCClient: %v", err)
+	}
+	_, err = rpcAttempt(context.Background(), c, "net_peerCount", []interface{}{})
+	if !errors.Is(err, ErrTransport) {
+		t.Errorf("rpcAttempt error = %v, want %v", err, ErrTransport)
+	}
+}
+
+// BenchmarkBlsKeyMatches measures looking up the signer of each key of a
+// block proof over a large validator set, as applyHeight does, against
+// normalizing every key first. Nodes differ in the case of the keys they
+// report, so both cases are covered.
+func BenchmarkBlsKeyMatches(b *testing.B) {
+	for _, format := range []string{"0x%096x", "0x%096X"} {
+		keys := make([]string, 1000)
+		for i := range keys {
+			keys[i] = fmt.Sprintf(format, i)
+		}
+		tv := &trackedValidator{label: "bench", blsKey: normalizeBlsKey(keys[len(keys)-1])}
+		m := &BlockTracker{
+			tracked:  []*trackedValidator{tv},
+			byBlsKey: map[string]*trackedValidator{tv.blsKey: tv},
+		}
+		name := "lower"
+		if format == "0x%096X" {
+			name = "upper"
+		}
+
+		b.Run(name+"/normalize", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, pk := range keys {
+					if m.byBlsKey[normalizeBlsKey(pk)] != nil {
+						break
+					}
+				}
+			}
+		})
+		b.Run(name+"/signer", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, pk := range keys {
+					if m.signer(pk) != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSigner(t *testing.T) {
+	key := strings.Repeat("ab", 48)
+	tv := &trackedValidator{label: "test", blsKey: key}
+	m := &BlockTracker{tracked: []*trackedValidator{tv}, byBlsKey: map[string]*trackedValidator{key: tv}}
+	for _, pk := range []string{key, "0x" + key, "0X" + strings.ToUpper(key), " 0x" + key + " ", "0x0000" + key} {
+		if m.signer(pk) != tv {
+			t.Errorf("signer(%q) = nil, want the tracked validator", pk)
+		}
+	}
+	for _, pk := range []string{"", "0x", strings.Repeat("cd", 48), strings.ToUpper(strings.Repeat("cd", 48))} {
+		if got := m.signer(pk); got != nil {
+			t.Errorf("signer(%q) = %v, want nil", pk, got.label)
+		}
+	}
+}
+
+// TestFetchBlockProofNotReady covers heights the node has no proof for yet,
+// which must be retried rather than counted as a missed vote.
+func TestFetchBlockProofNotReady(t *testing.T) {
+	replies := map[string]func(string) string{
+		"null result": result(`null`),
+		"absent result": func(string) string {
+			return `{"jsonrpc":"2.0","id":%s}`
+		},
+	}
+	for name, reply := range replies {
+		t.Run(name, func(t *testing.T) {
+			c, _ := newMockRPC(t, reply)
+			bp, err := fetchBlockProof(context.Background(), c, "debug_getBlockProof", "0x10", 1)
+			if !errors.Is(err, errNotReady) {
+				t.Errorf("fetchBlockProof = %+v, %v, want %v", bp, err, errNotReady)
+			}
+		})
+	}
+}
+
+func TestUnmarshalQuantity(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{`"0x1a2b"`, "0x1a2b", false},
+		{`6699`, "0x1a2b", false},
+		{`0`, "0x0", false},
+		{`123456789012345678901234567890`, "0x18ee90ff6c373e0ee4e3f0ad2", false},
+		{`-1`, "", true},
+		{`1.5`, "", true},
+		{`null`, "", true},
+		{`{}`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := unmarshalQuantity(json.RawMessage(tt.raw))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("unmarshalQuantity(%s) = %q, %v, want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFetchQuantityRepresentations(t *testing.T) {
+	for _, raw := range []string{`"0x14d1120d7b160000"`, `1500000000000000000`} {
+		c, _ := newMockRPC(t, result(raw))
+		height, err := fetchBlockNumber(context.Background(), c, "eth_blockNumber")
+		if err != nil || height != "0x14d1120d7b160000" {
+			t.Errorf("fetchBlockNumber with result %s = %q, %v", raw, height, err)
+		}
+		eth, err := fetchBalanceETH(context.Background(), c, "0x01", "latest", 18, 1)
+		if err != nil || eth != 1.5 {
+			t.Errorf("fetchBalanceETH with result %s = %v, %v, want 1.5", raw, eth, err)
+		}
+	}
+}
+
+func TestParseStaking(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"1000000000000000000000", "1000000000000000000000", false},
+		{"0x3635c9adc5dea00000", "1000000000000000000000", false},
+		{"0X3635C9ADC5DEA00000", "1000000000000000000000", false},
+		{" 42 ", "42", false},
+		{"0", "0", false},
+		{"", "", true},
+		{"0x", "", true},
+		{"-5", "", true},
+		{"1e21", "", true},
+		{"0xzz", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseStaking(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseStaking(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("parseStaking(%q) = %v, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// TestLearnStaking checks that an empty or invalid staking value warns once
+// and keeps the last staking gauge value instead of failing the tracker.
+func TestLearnStaking(t *testing.T) {
+	var out bytes.Buffer
+	m := &BlockTracker{cfg: BlockTrackerConfig{TokenDecimals: 18, Output: &out}}
+	tv := &trackedValidator{label: "test-staking"}
+	gauge := ValidatorStaking.WithLabelValues(tv.label)
+
+	m.learn(tv, ValidatorSetInfo{Staking: "0x1bc16d674ec80000"})
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Fatalf("staking = %v, want 2", got)
+	}
+	for _, bad := range []string{"", "not-a-number", ""} {
+		m.learn(tv, ValidatorSetInfo{Staking: bad})
+	}
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Errorf("staking after invalid values = %v, want 2", got)
+	}
+	if n := strings.Count(out.String(), "warning:"); n != 1 {
+		t.Errorf("logged %d warnings for repeated invalid staking, want 1:\n%s", n, out.String())
+	}
+}
+
+// TestRPCAttemptGzip serves a gzip body the client did not ask for, as some
+// gateways do, which the transport then leaves compressed.
+func TestRPCAttemptGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprintf(zw, `{"jsonrpc":"2.0","id":%s,"result":"0x1a2b"}`, req.ID)
+		zw.Close()
+	}))
+	defer srv.Close()
+	c, err := newRPCClient(BlockTrackerConfig{RPCURL: srv.URL, RPCTimeout: time.Second, CatchupConcurrency: 1})
+	if err != nil {
+		t.Fatalf("newRPCClient: %v", err)
+	}
+	c.httpClient.Transport.(*http.Transport).DisableCompression = true
+
+	raw, err := rpcAttempt(context.Background(), c, "eth_blockNumber", []interface{}{})
+	if err != nil {
+		t.Fatalf("rpcAttempt: %v", err)
+	}
+	if string(raw) != `"0x1a2b"` {
+		t.Errorf("rpcAttempt = %s, want %s", raw, `"0x1a2b"`)
+	}
+}
+
+// TestStartCancelDuringCatchup cancels a backfill while block proofs are in
+// flight: with -skip-failed-heights the cancelled fetches must stop the
+// tracker rather than be skipped as failed heights.
+func TestStartCancelDuringCatchup(t *testing.T) {
+	c, _ := newMockRPC(t, func(method string) string {
+		if method == "debug_getBlockProof" {
+			time.Sleep(200 * time.Millisecond)
+			return `{"jsonrpc":"2.0","id":%s,"result":{"signedBlsKeys":[]}}`
+		}
+		return `{"jsonrpc":"2.0","id":%s,"result":"0x100"}`
+	})
+	var out bytes.Buffer
+	m, err := NewBlockTracker(BlockTrackerConfig{
+		RPCURL:             c.url,
+		MyBlsKey:           "0x" + strings.Repeat("ab", 48),
+		CheckBlockProof:    true,
+		FromHeight:         "1",
+		SkipFailedHeights:  true,
+		CatchupConcurrency: 4,
+		Output:             &out,
+	})
+	if err != nil {
+		t.Fatalf("NewBlockTracker: %v", err)
+	}
+	skipped := testutil.ToFloat64(BlocksSkippedTotal)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := m.Start(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Start error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := testutil.ToFloat64(BlocksSkippedTotal) - skipped; got != 0 {
+		t.Errorf("skipped %v heights on cancel, want 0:\n%s", got, out.String())
+	}
+	if got := m.lastChecked.Load(); got != 0 {
+		t.Errorf("lastChecked = %d after cancel, want 0", got)
+	}
+}
+
+// TestUpdateBalancesMissingState covers an RPC without the state for
+// -balance-block-tag: the balance check must give up instead of stalling.
+func TestUpdateBalancesMissingState(t *testing.T) {
+	c, calls := newMockRPC(t, rpcErrorReply(-32000, "missing trie node"))
+	var out bytes.Buffer
+	m, err := NewBlockTracker(BlockTrackerConfig{
+		RPCURL:          c.url,
+		MyAddress:       "0x" + strings.Repeat("12", 20),
+		CheckBalance:    true,
+		BalanceBlockTag: "16",
+		Output:          &out,
+	})
+	if err != nil {
+		t.Fatalf("NewBlockTracker: %v", err)
+	}
+	for i := 0; i < optionalCheckMaxFailures; i++ {
+		if err := m.updateBalances(context.Background()); err != nil {
+			t.Fatalf("updateBalances: %v", err)
+		}
+	}
+	if !m.balanceOff {
+		t.Fatalf("balance check still enabled after %d failed polls:\n%s", optionalCheckMaxFailures, out.String())
+	}
+	if want := int64(optionalCheckMaxFailures * optionalCheckAttempts); calls.Load() != want {
+		t.Errorf("served %d requests, want %d", calls.Load(), want)
+	}
+	if err := m.updateBalances(context.Background()); err != nil || calls.Load() != int64(optionalCheckMaxFailures*optionalCheckAttempts) {
+		t.Errorf("disabled balance check still polled: %v", err)
+	}
+}
+
+// TestUpdateBalancesRevertingToken covers a -token-contracts entry that is not
+// an ERC-20 contract: its eth_call reverts, and the token is dropped after
+// a few polls while the address balance keeps updating.
+func TestUpdateBalancesRevertingToken(t *testing.T) {
+	c, _ := newMockRPC(t, func(method string) string {
+		if method == "eth_call" {
+			return rpcErrorReply(3, "execution reverted")(method)
+		}
+		return result(`"0x14d1120d7b160000"`)(method)
+	})
+	var out bytes.Buffer
+	address := "0x" + strings.Repeat("34", 20)
+	token := "0x" + strings.Repeat("56", 20)
+	m, err := NewBlockTracker(BlockTrackerConfig{
+		RPCURL:         c.url,
+		MyAddress:      address,
+		CheckBalance:   true,
+		TokenContracts: []string{token},
+		TokenDecimals:  18,
+		Output:         &out,
+	})
+	if err != nil {
+		t.Fatalf("NewBlockTracker: %v", err)
+	}
+	for i := 0; i < optionalCheckMaxFailures; i++ {
+		if err := m.updateBalances(context.Background()); err != nil {
+			t.Fatalf("updateBalances: %v", err)
+		}
+		if got := testutil.ToFloat64(AddressBalanceETH.WithLabelValues(address)); got != 1.5 {
+			t.Fatalf("address balance = %v, want 1.5", got)
+		}
+	}
+	if len(m.tokens) != 0 {
+		t.Errorf("tokens = %v after %d reverted polls, want the token dropped:\n%s", m.tokens, optionalCheckMaxFailures, out.String())
+	}
+	if n := strings.Count(out.String(), "warning: token "+token); n != optionalCheckMaxFailures {
+		t.Errorf("logged %d token warnings, want %d:\n%s", n, optionalCheckMaxFailures, out.String())
+	}
+}
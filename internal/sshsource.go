@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// sshScheme marks a log path that is tailed on a remote host through the
+// system ssh client, e.g. ssh://user@host:22/var/log/pharos/node.log.
+const sshScheme = "ssh://"
+
+// sshTarget is a parsed ssh:// log path.
+type sshTarget struct {
+	dest string // [user@]host
+	port string
+	path string
+}
+
+func parseSSHPath(raw string) (*sshTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh log path %q: %w", raw, err)
+	}
+	if u.Hostname() == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("invalid ssh log path %q: expected ssh://[user@]host[:port]/path", raw)
+	}
+	dest := u.Hostname()
+	if u.User != nil && u.User.Username() != "" {
+		dest = u.User.Username() + "@" + dest
+	}
+	return &sshTarget{dest: dest, port: u.Port(), path: u.Path}, nil
+}
+
+// command returns the ssh invocation following the remote file with tail -F,
+// starting at its first line when fromStart is set and at its end otherwise.
+func (s *sshTarget) command(ctx context.Context, fromStart bool) *exec.Cmd {
+	lines := "0"
+	if fromStart {
+		lines = "+1"
+	}
+	args := []string{"-o", "BatchMode=yes", "-o", "ServerAliveInterval=30"}
+	if s.port != "" {
+		args = append(args, "-p", s.port)
+	}
+	args = append(args, s.dest, "--", "tail", "-n", lines, "-F", shellQuote(s.path))
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// sshStableAfter is how long an ssh session must last to count as
+// established: the next reconnect after it starts again from the poll
+// interval instead of the grown delay.
+var sshStableAfter = time.Minute
+
+// sshSource follows a remote log through the system ssh client.
+type sshSource struct {
+	t       *LogTailer
+	target  *sshTarget
+	started bool
+}
+
+// follow streams the remote log until ctx is cancelled. When the ssh session
+// ends it is restarted after a delay that grows while sessions keep failing;
+// a restarted session continues at the end of the file so no event is counted
+// twice.
+func (s *sshSource) follow(ctx context.Context) error {
+	t := s.t
+	fromStart := t.cfg.FromStart && !s.started
+	s.started = true
+	delay := t.cfg.PollInterval
+	for {
+		begin := time.Now()
+		err := s.run(ctx, fromStart)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(begin) >= sshStableAfter {
+			delay = t.cfg.PollInterval
+		}
+		SubsystemRestartsTotal.WithLabelValues("log_tailer").Inc()
+		fmt.Fprintf(t.cfg.Output, "ssh log stream from %s ended, reconnecting in %s: %v\n", s.target.dest, delay, err)
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return err
+		}
+		delay = min(delay*2, time.Minute)
+		fromStart = false
+	}
+}
+
+func (s *sshSource) run(ctx context.Context, fromStart bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := s.target.command(ctx, fromStart)
+	cmd.Stderr = s.t.cfg.Output
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	streamErr := s.t.stream(ctx, stdout)
+	cancel()
+	waitErr := cmd.Wait()
+	if streamErr != nil {
+		return streamErr
+	}
+	return waitErr
+}
+
+// shellQuote quotes s for the remote shell that ssh hands the command to.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
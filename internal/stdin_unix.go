@@ -0,0 +1,30 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// pollableStdin returns standard input as a file managed by the runtime poller
+// when it is a pipe or socket, so a pending read can be interrupted with a
+// read deadline on shutdown. A stdin inherited in blocking mode is not taken
+// over by the poller until it is switched to non-blocking and wrapped anew.
+// Terminals, regular files and an already pollable stdin are returned
+// unchanged.
+func pollableStdin() *os.File {
+	if os.Stdin.SetReadDeadline(time.Time{}) == nil {
+		return os.Stdin
+	}
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&(os.ModeNamedPipe|os.ModeSocket) == 0 {
+		return os.Stdin
+	}
+	fd := os.Stdin.Fd()
+	if err := syscall.SetNonblock(int(fd), true); err != nil {
+		return os.Stdin
+	}
+	return os.NewFile(fd, os.Stdin.Name())
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// inheritedPipe returns a pipe whose read end is in blocking mode and unknown
+// to the runtime poller, like a stdin inherited from a shell pipeline.
+func inheritedPipe() (*os.File, *os.File, error) {
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil {
+		return nil, nil, err
+	}
+	return os.NewFile(uintptr(fds[0]), "|0"), os.NewFile(uintptr(fds[1]), "|1"), nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package internal
+
+import "os"
+
+// pollableStdin returns standard input; Windows pipes support no read
+// deadlines, so a pending read is left until the next line or EOF.
+func pollableStdin() *os.File {
+	return os.Stdin
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package internal
+
+import "os"
+
+// inheritedPipe returns an anonymous pipe, standing in for a piped stdin.
+func inheritedPipe() (*os.File, *os.File, error) {
+	return os.Pipe()
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	erc20BalanceOfSelector = "0x70a08231"
+	erc20DecimalsSelector  = "0x313ce567"
+)
+
+// updateTokenBalances refreshes the ERC-20 balances of the tracked address.
+// A token whose calls keep failing, e.g. an address that is not an ERC-20
+// contract and reverts, is warned about and dropped after
+// optionalCheckMaxFailures polls, leaving the other tokens and block
+// processing unaffected.
+func (m *BlockTracker) updateTokenBalances(ctx context.Context) error {
+	var kept []string
+	for _, token := range m.tokens {
+		balance, err := m.tokenBalance(ctx, token)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures := m.tokenFailures[token]
+			if m.optionalCheckFailed("token "+token+" balance check", &failures, err) {
+				delete(m.tokenFailures, token)
+				TokenBalance.DeleteLabelValues(token, m.address)
+				continue
+			}
+			m.tokenFailures[token] = failures
+			kept = append(kept, token)
+			continue
+		}
+		delete(m.tokenFailures, token)
+		TokenBalance.WithLabelValues(token, m.address).Set(balance)
+		kept = append(kept, token)
+	}
+	m.tokens = kept
+	return nil
+}
+
+// tokenBalance returns the ERC-20 balance of the tracked address in whole
+// token units. The token's decimals() is queried once and cached.
+func (m *BlockTracker) tokenBalance(ctx context.Context, token string) (float64, error) {
+	decimals, ok := m.tokenDecimals[token]
+	if !ok {
+		d, err := fetchTokenDecimals(ctx, m.rpc, token, optionalCheckAttempts)
+		if err != nil {
+			return 0, err
+		}
+		decimals = d
+		m.tokenDecimals[token] = decimals
+	}
+	raw, err := fetchTokenBalance(ctx, m.rpc, token, m.address, optionalCheckAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return scaleDecimals(raw, decimals), nil
+}
+
+func fetchTokenBalance(ctx context.Context, c *rpcClient, token, address string, maxAttempts int) (*big.Int, error) {
+	data := erc20BalanceOfSelector + fmt.Sprintf("%064s", trim0x(address))
+	hexStr, err := ethCall(ctx, c, token, data, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseHexBigInt(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balanceOf result: %w", err)
+	}
+	return v, nil
+}
+
+func fetchTokenDecimals(ctx context.Context, c *rpcClient, token string, maxAttempts int) (int, error) {
+	hexStr, err := ethCall(ctx, c, token, erc20DecimalsSelector, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseHexBigInt(hexStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimals result: %w", err)
+	}
+	if !v.IsInt64() || v.Int64() > 77 {
+		return 0, fmt.Errorf("invalid decimals result: %s", v)
+	}
+	return int(v.Int64()), nil
+}
+
+func ethCall(ctx context.Context, c *rpcClient, to, data string, maxAttempts int) (string, error) {
+	call := map[string]string{"to": to, "data": data}
+	resultRaw, err := rpcPostAttempts(ctx, c, "eth_call", []interface{}{call, "latest"}, maxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("rpc call eth_call failed: %w", err)
+	}
+	var hexStr string
+	if err := json.Unmarshal(resultRaw, &hexStr); err != nil {
+		return "", fmt.Errorf("parse eth_call result failed: %w", err)
+	}
+	if strings.TrimSpace(trim0x(hexStr)) == "" {
+		return "", fmt.Errorf("empty eth_call result from %s (not a contract?)", to)
+	}
+	return hexStr, nil
+}